@@ -0,0 +1,340 @@
+package accounts
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/panicpanicpanic/filament"
+	"github.com/panicpanicpanic/filament/device"
+	"github.com/panicpanicpanic/filament/lifx"
+)
+
+// DefaultCacheTTL is how long a Client routes calls using the lights and scenes it last listed
+const DefaultCacheTTL = 5 * time.Minute
+
+// Account is a single LIFX account managed by a Client
+type Account struct {
+	Name   string
+	Tokens TokenSource
+
+	// Client holds any other settings for the account, such as BaseURL, Limiter or Observers.
+	// Its AccessToken is replaced with one from Tokens for every call.
+	Client lifx.Client
+}
+
+// Device is a device.Device tagged with the name of the Account it belongs to
+type Device struct {
+	device.Device
+	Account string
+}
+
+// Scene is a device.Scene tagged with the name of the Account it belongs to
+type Scene struct {
+	device.Scene
+	Account string
+}
+
+// Client merges several LIFX accounts into one, sending each call to the accounts that own the
+// lights it targets
+type Client struct {
+	// CacheTTL is how long the lights and scenes used for routing are kept, defaulting to DefaultCacheTTL
+	CacheTTL time.Duration
+
+	accounts []Account
+
+	mu        sync.Mutex
+	devices   []Device
+	devicesAt time.Time
+	scenes    []Scene
+	scenesAt  time.Time
+}
+
+// New returns a Client for the given accounts. Account names must be unique.
+func New(accounts ...Account) (*Client, error) {
+	names := make(map[string]bool)
+
+	for _, account := range accounts {
+		if names[account.Name] {
+			return nil, fmt.Errorf("account %q is listed more than once", account.Name)
+		}
+		if account.Tokens == nil {
+			return nil, fmt.Errorf("account %q has no token source", account.Name)
+		}
+		names[account.Name] = true
+	}
+
+	return &Client{accounts: accounts}, nil
+}
+
+// GetLights returns the lights within the given selector from every account, tagged by account.
+// If some accounts fail, the lights from the rest are returned along with an error, and aren't
+// cached for routing.
+func (c *Client) GetLights(ctx context.Context, selector string) ([]Device, error) {
+	var devices []Device
+
+	results := make([][]device.Device, len(c.accounts))
+	err := c.each(ctx, c.accounts, func(i int, client *lifx.Client) error {
+		var err error
+		results[i], err = filament.GetLights(client, selector)
+		return err
+	})
+
+	for i, found := range results {
+		for _, d := range found {
+			devices = append(devices, Device{Device: d, Account: c.accounts[i].Name})
+		}
+	}
+
+	if err == nil && (selector == "" || selector == "all") {
+		c.mu.Lock()
+		c.devices, c.devicesAt = devices, time.Now()
+		c.mu.Unlock()
+	}
+
+	return devices, err
+}
+
+// GetScenes returns the scenes from every account, tagged by account
+func (c *Client) GetScenes(ctx context.Context) ([]Scene, error) {
+	var scenes []Scene
+
+	results := make([][]device.Scene, len(c.accounts))
+	err := c.each(ctx, c.accounts, func(i int, client *lifx.Client) error {
+		var err error
+		results[i], err = filament.GetScenes(client)
+		return err
+	})
+
+	for i, found := range results {
+		for _, s := range found {
+			scenes = append(scenes, Scene{Scene: s, Account: c.accounts[i].Name})
+		}
+	}
+
+	if err == nil {
+		c.mu.Lock()
+		c.scenes, c.scenesAt = scenes, time.Now()
+		c.mu.Unlock()
+	}
+
+	return scenes, err
+}
+
+// Owners returns the accounts owning lights within the given selector. It uses the lights from the
+// last GetLights("all") if they are younger than CacheTTL, fetching them again if not, or if none of
+// the cached lights match, since the selector may target a light added since.
+func (c *Client) Owners(ctx context.Context, selector string) ([]Account, error) {
+	if selector == "" {
+		selector = "all"
+	}
+
+	c.mu.Lock()
+	devices := c.devices
+	if c.expired(c.devicesAt) {
+		devices = nil
+	}
+	c.mu.Unlock()
+
+	if devices != nil {
+		if owners := c.owners(devices, selector); len(owners) > 0 {
+			return owners, nil
+		}
+	}
+
+	devices, err := c.GetLights(ctx, "all")
+	if err != nil {
+		return nil, err
+	}
+
+	owners := c.owners(devices, selector)
+	if len(owners) == 0 {
+		return nil, fmt.Errorf("no account has lights matching %q", selector)
+	}
+
+	return owners, nil
+}
+
+func (c *Client) owners(devices []Device, selector string) []Account {
+	var owners []Account
+
+	for _, account := range c.accounts {
+		for _, d := range devices {
+			if d.Account == account.Name && d.Matches(selector) {
+				owners = append(owners, account)
+				break
+			}
+		}
+	}
+
+	return owners
+}
+
+// expired returns true if something cached at the given time is older than CacheTTL
+func (c *Client) expired(at time.Time) bool {
+	ttl := c.CacheTTL
+	if ttl <= 0 {
+		ttl = DefaultCacheTTL
+	}
+
+	return time.Since(at) > ttl
+}
+
+// SetState sets the state of the lights within the given selector, in whichever accounts own them
+func (c *Client) SetState(ctx context.Context, selector string, payload interface{}) (lifx.Response, error) {
+	return c.route(ctx, selector, func(client *lifx.Client) (lifx.Response, error) {
+		return filament.SetState(client, selector, payload)
+	})
+}
+
+// TogglePower toggles the power of the lights within the given selector, in whichever accounts own them
+func (c *Client) TogglePower(ctx context.Context, selector string) (lifx.Response, error) {
+	return c.route(ctx, selector, func(client *lifx.Client) (lifx.Response, error) {
+		return filament.TogglePower(client, selector)
+	})
+}
+
+// StateDelta changes the state of the lights within the given selector, in whichever accounts own them
+func (c *Client) StateDelta(ctx context.Context, selector string, payload interface{}) (lifx.Response, error) {
+	return c.route(ctx, selector, func(client *lifx.Client) (lifx.Response, error) {
+		return filament.StateDelta(client, selector, payload)
+	})
+}
+
+// Cycle cycles the lights within the given selector, in whichever accounts own them
+func (c *Client) Cycle(ctx context.Context, selector string, payload interface{}) (lifx.Response, error) {
+	return c.route(ctx, selector, func(client *lifx.Client) (lifx.Response, error) {
+		return filament.Cycle(client, selector, payload)
+	})
+}
+
+// PulseEffect performs a pulse effect on the lights within the given selector, in whichever accounts own them
+func (c *Client) PulseEffect(ctx context.Context, selector string, payload interface{}) (lifx.Response, error) {
+	return c.route(ctx, selector, func(client *lifx.Client) (lifx.Response, error) {
+		return filament.PulseEffect(client, selector, payload)
+	})
+}
+
+// BreatheEffect performs a breathe effect on the lights within the given selector, in whichever accounts own them
+func (c *Client) BreatheEffect(ctx context.Context, selector string, payload interface{}) (lifx.Response, error) {
+	return c.route(ctx, selector, func(client *lifx.Client) (lifx.Response, error) {
+		return filament.BreatheEffect(client, selector, payload)
+	})
+}
+
+// ActivateScene activates a scene in the account it belongs to. Like Owners, it uses the scenes from
+// the last GetScenes if they are younger than CacheTTL and contain the scene.
+func (c *Client) ActivateScene(ctx context.Context, sceneUUID string, payload interface{}) (lifx.Response, error) {
+	c.mu.Lock()
+	scenes := c.scenes
+	if c.expired(c.scenesAt) {
+		scenes = nil
+	}
+	c.mu.Unlock()
+
+	account, ok := c.sceneAccount(scenes, sceneUUID)
+	if !ok {
+		var err error
+		scenes, err = c.GetScenes(ctx)
+		if err != nil {
+			return lifx.Response{}, err
+		}
+
+		account, ok = c.sceneAccount(scenes, sceneUUID)
+		if !ok {
+			return lifx.Response{}, fmt.Errorf("no account has scene %q", sceneUUID)
+		}
+	}
+
+	client, err := account.client(ctx)
+	if err != nil {
+		return lifx.Response{}, err
+	}
+	return filament.ActivateScene(client, sceneUUID, payload)
+}
+
+func (c *Client) sceneAccount(scenes []Scene, sceneUUID string) (Account, bool) {
+	for _, scene := range scenes {
+		if scene.UUID != sceneUUID {
+			continue
+		}
+
+		for _, account := range c.accounts {
+			if account.Name == scene.Account {
+				return account, true
+			}
+		}
+	}
+
+	return Account{}, false
+}
+
+// route sends a call to every account owning lights within the selector and merges their results
+func (c *Client) route(ctx context.Context, selector string, call func(*lifx.Client) (lifx.Response, error)) (lifx.Response, error) {
+	var merged lifx.Response
+
+	owners, err := c.Owners(ctx, selector)
+	if err != nil {
+		return merged, err
+	}
+
+	responses := make([]lifx.Response, len(owners))
+	err = c.each(ctx, owners, func(i int, client *lifx.Client) error {
+		var err error
+		responses[i], err = call(client)
+		return err
+	})
+
+	for _, response := range responses {
+		merged.Results = append(merged.Results, response.Results...)
+	}
+
+	return merged, err
+}
+
+// each runs call concurrently against every account, combining any errors into one
+func (c *Client) each(ctx context.Context, accounts []Account, call func(int, *lifx.Client) error) error {
+	var wg sync.WaitGroup
+	var failures []string
+
+	errs := make([]error, len(accounts))
+	for i, account := range accounts {
+		wg.Add(1)
+		go func(i int, account Account) {
+			defer wg.Done()
+
+			client, err := account.client(ctx)
+			if err == nil {
+				err = call(i, client)
+			}
+			errs[i] = err
+		}(i, account)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			failures = append(failures, accounts[i].Name+": "+err.Error())
+		}
+	}
+
+	if len(failures) > 0 {
+		return fmt.Errorf("%s", strings.Join(failures, "; "))
+	}
+	return nil
+}
+
+// client returns a copy of the account's Client with a fresh AccessToken
+func (a Account) client(ctx context.Context) (*lifx.Client, error) {
+	token, err := a.Tokens.Token(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get a token: %s", err)
+	}
+
+	client := a.Client
+	client.AccessToken = token
+	client.Context = ctx
+	return &client, nil
+}
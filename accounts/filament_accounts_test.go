@@ -0,0 +1,150 @@
+package accounts_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/panicpanicpanic/filament/accounts"
+	"github.com/panicpanicpanic/filament/lifx"
+)
+
+func TestClient(t *testing.T) {
+	var officeCalls, labCalls int
+
+	fakeAccount := func(label string, calls *int) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodGet {
+				w.WriteHeader(http.StatusOK)
+				w.Write([]byte(`[{"id": "` + label + `", "label": "` + label + `"}]`))
+				return
+			}
+
+			*calls++
+			w.WriteHeader(http.StatusMultiStatus)
+			w.Write([]byte(`{"results": [{"id": "` + label + `", "status": "ok", "label": "` + label + `"}]}`))
+		}))
+	}
+
+	office := fakeAccount("Desk", &officeCalls)
+	defer office.Close()
+	lab := fakeAccount("Bench", &labCalls)
+	defer lab.Close()
+
+	client, err := accounts.New(
+		accounts.Account{Name: "office", Tokens: accounts.StaticToken("a"), Client: lifx.Client{BaseURL: office.URL}},
+		accounts.Account{Name: "lab", Tokens: accounts.StaticToken("b"), Client: lifx.Client{BaseURL: lab.URL}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("when listing lights across accounts", func(t *testing.T) {
+		devices, err := client.GetLights(context.Background(), "all")
+		if err != nil {
+			t.Fatalf("it should have listed lights, got %s", err)
+		}
+		if len(devices) != 2 || devices[0].Account != "office" || devices[1].Account != "lab" {
+			t.Errorf("it should have tagged each light with its account, got %+v", devices)
+		}
+	})
+
+	t.Run("when a selector only matches one account", func(t *testing.T) {
+		response, err := client.SetState(context.Background(), "label:Bench", map[string]interface{}{"power": "on"})
+		if err != nil {
+			t.Fatalf("it should have set the state, got %s", err)
+		}
+		if labCalls != 1 || officeCalls != 0 || len(response.Results) != 1 {
+			t.Errorf("it should have only called the lab account, got office=%d lab=%d", officeCalls, labCalls)
+		}
+	})
+
+	t.Run("when a selector matches every account", func(t *testing.T) {
+		response, err := client.TogglePower(context.Background(), "all")
+		if err != nil {
+			t.Fatalf("it should have toggled the lights, got %s", err)
+		}
+		if len(response.Results) != 2 {
+			t.Errorf("it should have merged the results from both accounts, got %+v", response)
+		}
+	})
+
+	t.Run("when no account owns the selector", func(t *testing.T) {
+		_, err := client.SetState(context.Background(), "label:Nowhere", nil)
+		if err == nil {
+			t.Errorf("it should have refused to send the request")
+		}
+	})
+}
+
+func TestClientCache(t *testing.T) {
+	var gets int
+	labFailing := true
+	officeLights := `[{"id": "d1", "label": "Desk"}]`
+
+	office := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gets++
+		w.Write([]byte(officeLights))
+	}))
+	defer office.Close()
+
+	lab := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if labFailing {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte(`[{"id": "d2", "label": "Bench"}]`))
+	}))
+	defer lab.Close()
+
+	client, err := accounts.New(
+		accounts.Account{Name: "office", Tokens: accounts.StaticToken("a"), Client: lifx.Client{BaseURL: office.URL}},
+		accounts.Account{Name: "lab", Tokens: accounts.StaticToken("b"), Client: lifx.Client{BaseURL: lab.URL}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("when an account fails to list its lights", func(t *testing.T) {
+		client.GetLights(context.Background(), "all")
+		labFailing = false
+		gets = 0
+
+		owners, err := client.Owners(context.Background(), "label:Bench")
+		if err != nil || len(owners) != 1 || owners[0].Name != "lab" || gets != 1 {
+			t.Errorf("it should not have cached the partial list, got %v and %d GETs", err, gets)
+		}
+	})
+
+	t.Run("when the cached lights match", func(t *testing.T) {
+		gets = 0
+
+		_, err := client.Owners(context.Background(), "label:Desk")
+		if err != nil || gets != 0 {
+			t.Errorf("it should have used the cached lights, got %v and %d GETs", err, gets)
+		}
+	})
+
+	t.Run("when a light was added after the lights were cached", func(t *testing.T) {
+		gets = 0
+		officeLights = `[{"id": "d1", "label": "Desk"}, {"id": "d3", "label": "Lamp"}]`
+
+		owners, err := client.Owners(context.Background(), "label:Lamp")
+		if err != nil || len(owners) != 1 || owners[0].Name != "office" || gets != 1 {
+			t.Errorf("it should have refreshed the lights, got %v and %d GETs", err, gets)
+		}
+	})
+
+	t.Run("when the cached lights are older than CacheTTL", func(t *testing.T) {
+		gets = 0
+		client.CacheTTL = time.Nanosecond
+		time.Sleep(time.Millisecond)
+
+		_, err := client.Owners(context.Background(), "label:Desk")
+		if err != nil || gets != 1 {
+			t.Errorf("it should have refreshed the lights, got %v and %d GETs", err, gets)
+		}
+	})
+}
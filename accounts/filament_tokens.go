@@ -0,0 +1,100 @@
+package accounts
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TokenSource provides an account's AccessToken. It is asked for the token before every call, so
+// a source that returns a new token is all it takes to rotate one.
+type TokenSource interface {
+	Token(ctx context.Context) (string, error)
+}
+
+// TokenFunc adapts a function, such as a lookup in a secret manager, into a TokenSource
+type TokenFunc func(ctx context.Context) (string, error)
+
+// Token calls f
+func (f TokenFunc) Token(ctx context.Context) (string, error) {
+	return f(ctx)
+}
+
+// StaticToken is a TokenSource that always returns the same token
+type StaticToken string
+
+// Token returns the token
+func (s StaticToken) Token(ctx context.Context) (string, error) {
+	return string(s), nil
+}
+
+// EnvToken is a TokenSource that reads the token from the named environment variable
+type EnvToken string
+
+// Token returns the value of the environment variable
+func (e EnvToken) Token(ctx context.Context) (string, error) {
+	token := os.Getenv(string(e))
+	if token == "" {
+		return "", fmt.Errorf("environment variable %s is not set", string(e))
+	}
+	return token, nil
+}
+
+// FileToken is a TokenSource that reads the token from the file at its path, so replacing the
+// file rotates the token
+type FileToken string
+
+// Token returns the contents of the file with surrounding whitespace removed
+func (f FileToken) Token(ctx context.Context) (string, error) {
+	data, err := ioutil.ReadFile(string(f))
+	if err != nil {
+		return "", fmt.Errorf(err.Error())
+	}
+
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return "", fmt.Errorf("token file %s is empty", string(f))
+	}
+	return token, nil
+}
+
+// CachedToken wraps a slow TokenSource, such as a remote secret manager, and only asks it for the
+// token again once TTL has passed or Invalidate is called
+type CachedToken struct {
+	Source TokenSource
+	TTL    time.Duration
+
+	mu      sync.Mutex
+	token   string
+	fetched time.Time
+}
+
+// Token returns the cached token, refreshing it from Source when it has expired
+func (c *CachedToken) Token(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.token != "" && time.Since(c.fetched) < c.TTL {
+		return c.token, nil
+	}
+
+	token, err := c.Source.Token(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	c.token, c.fetched = token, time.Now()
+	return token, nil
+}
+
+// Invalidate forces the next call to Token to ask Source again, e.g. after LIFX rejects the token
+func (c *CachedToken) Invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.token = ""
+}
@@ -0,0 +1,435 @@
+package animation
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/panicpanicpanic/filament"
+	"github.com/panicpanicpanic/filament/device"
+	"github.com/panicpanicpanic/filament/lifx"
+)
+
+const (
+	// DefaultTolerance is how far (0.0 - 1.0) a planned transition may stray from its Easing curve
+	DefaultTolerance = 0.05
+
+	// maxSegments caps how many SetState calls a single eased transition is split into
+	maxSegments = 16
+)
+
+// Easing maps the linear progress of a transition (0.0 - 1.0) onto eased progress (0.0 - 1.0)
+type Easing func(t float64) float64
+
+// Linear progresses at a constant rate, which LIFX can interpolate in a single call
+func Linear(t float64) float64 {
+	return t
+}
+
+// EaseIn starts slowly and accelerates
+func EaseIn(t float64) float64 {
+	return t * t
+}
+
+// EaseOut starts quickly and decelerates
+func EaseOut(t float64) float64 {
+	return t * (2 - t)
+}
+
+// EaseInOut accelerates through the first half and decelerates through the second
+func EaseInOut(t float64) float64 {
+	if t < 0.5 {
+		return 2 * t * t
+	}
+	return -1 + (4-2*t)*t
+}
+
+// Keyframe is the state lights should reach At the given offset from the start of an Animation
+type Keyframe struct {
+	At    time.Duration
+	Power string
+
+	// Color, if set, is the color to reach. A Keyframe without one leaves the lights' color alone.
+	Color      *device.Color
+	Brightness float64
+
+	// Easing shapes the transition from the previous Keyframe into this one; nil means Linear
+	Easing Easing
+}
+
+// Animation is a sequence of Keyframes played against a selector
+type Animation struct {
+	Selector  string
+	Keyframes []Keyframe
+	Loop      bool
+
+	// Tolerance defaults to DefaultTolerance when zero
+	Tolerance float64
+
+	// MinInterval is the shortest time allowed between two calls, defaulting to the LIFX rate limit
+	MinInterval time.Duration
+}
+
+// Step is a single SetState call made while playing an Animation
+type Step struct {
+	At         time.Duration
+	Duration   time.Duration
+	Power      string
+	Color      *device.Color
+	Brightness float64
+}
+
+// Validate returns an error if the Animation cannot be played
+func (a Animation) Validate() error {
+	if len(a.Keyframes) == 0 {
+		return fmt.Errorf("an Animation must have at least one Keyframe")
+	}
+
+	for i, keyframe := range a.Keyframes {
+		if keyframe.At < 0 {
+			return fmt.Errorf("keyframe %d has a negative offset", i)
+		}
+		if i > 0 && keyframe.At <= a.Keyframes[i-1].At {
+			return fmt.Errorf("keyframe %d must come after keyframe %d", i, i-1)
+		}
+		if keyframe.Brightness < 0 || keyframe.Brightness > 1 {
+			return fmt.Errorf("keyframe %d brightness must be between 0.0 and 1.0", i)
+		}
+	}
+
+	if a.Loop && a.Keyframes[len(a.Keyframes)-1].At == 0 {
+		return fmt.Errorf("a looping Animation must last longer than 0s")
+	}
+
+	return nil
+}
+
+// Plan returns the Steps needed to play the Animation once from the lights' current state
+func (a Animation) Plan() []Step {
+	return a.plan(true)
+}
+
+// plan returns the Steps for one iteration. The first iteration starts from an unknown state, so its
+// opening transition is always a single call; later iterations ease in from the last Keyframe.
+func (a Animation) plan(first bool) []Step {
+	var steps []Step
+
+	tolerance := a.Tolerance
+	if tolerance <= 0 {
+		tolerance = DefaultTolerance
+	}
+
+	minInterval := a.MinInterval
+	if minInterval <= 0 {
+		minInterval = lifx.DefaultRatePeriod / lifx.DefaultRateLimit
+	}
+
+	for i, keyframe := range a.Keyframes {
+		var from Keyframe
+		var start time.Duration
+
+		if i > 0 {
+			from = a.Keyframes[i-1]
+			start = from.At
+		} else if first {
+			steps = append(steps, keyframeStep(keyframe, 0, keyframe.At, keyframe.Power))
+			continue
+		} else {
+			from = a.Keyframes[len(a.Keyframes)-1]
+		}
+
+		length := keyframe.At - start
+		segments := segmentsFor(keyframe.Easing, length, minInterval, tolerance)
+		ease := keyframe.Easing
+		if ease == nil {
+			ease = Linear
+		}
+
+		for s := 0; s < segments; s++ {
+			progress := ease(float64(s+1) / float64(segments))
+			at := start + length*time.Duration(s)/time.Duration(segments)
+			end := start + length*time.Duration(s+1)/time.Duration(segments)
+
+			// Turning on happens at the start of a transition, turning off only once it is finished
+			power := ""
+			if keyframe.Power == "on" || s == segments-1 {
+				power = keyframe.Power
+			}
+
+			step := keyframeStep(keyframe, at, end-at, power)
+			step.Color = lerpColor(from.Color, keyframe.Color, progress)
+			step.Brightness = lerp(from.Brightness, keyframe.Brightness, progress)
+			steps = append(steps, step)
+		}
+	}
+
+	return steps
+}
+
+// Payload returns the SetState payload for the Step
+func (s Step) Payload() map[string]interface{} {
+	payload := map[string]interface{}{
+		"brightness": s.Brightness,
+		"duration":   s.Duration.Seconds(),
+	}
+
+	if s.Color != nil {
+		payload["color"] = s.Color.String()
+	}
+	if s.Power != "" {
+		payload["power"] = s.Power
+	}
+
+	return payload
+}
+
+// Player plays an Animation and lets callers pause and resume it while it runs
+type Player struct {
+	client    lifx.Client
+	animation Animation
+
+	mu      sync.Mutex
+	paused  bool
+	changed chan struct{}
+
+	base    time.Time
+	current *Step
+	prev    *Step
+}
+
+// NewPlayer returns a Player for the Animation. The client is copied, but shares its Limiter.
+func NewPlayer(client *lifx.Client, animation Animation) (*Player, error) {
+	err := animation.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	if animation.Selector == "" {
+		animation.Selector = "all"
+	}
+
+	return &Player{
+		client:    *client,
+		animation: animation,
+		changed:   make(chan struct{}, 1),
+	}, nil
+}
+
+// Play plays the Animation until it finishes or ctx is done
+func Play(ctx context.Context, client *lifx.Client, animation Animation) error {
+	player, err := NewPlayer(client, animation)
+	if err != nil {
+		return err
+	}
+
+	return player.Run(ctx)
+}
+
+// Run plays the Animation until it finishes, or forever if it loops, stopping early when ctx is done
+func (p *Player) Run(ctx context.Context) error {
+	p.client.Context = ctx
+	p.base = time.Now()
+
+	for iteration := 0; ; iteration++ {
+		steps := p.animation.plan(iteration == 0)
+
+		for i := range steps {
+			err := p.waitUntil(ctx, steps[i].At)
+			if err != nil {
+				return err
+			}
+
+			err = p.send(steps[i])
+			if err != nil {
+				return err
+			}
+
+			p.prev, p.current = p.current, &steps[i]
+		}
+
+		length := p.animation.Keyframes[len(p.animation.Keyframes)-1].At
+		if !p.animation.Loop {
+			return p.waitUntil(ctx, length)
+		}
+
+		p.base = p.base.Add(length)
+	}
+}
+
+// Pause freezes the lights at their current point in the Animation until Resume is called
+func (p *Player) Pause() {
+	p.setPaused(true)
+}
+
+// Resume continues a paused Animation from where it was paused
+func (p *Player) Resume() {
+	p.setPaused(false)
+}
+
+func (p *Player) setPaused(paused bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.paused == paused {
+		return
+	}
+	p.paused = paused
+
+	select {
+	case p.changed <- struct{}{}:
+	default:
+	}
+}
+
+func (p *Player) isPaused() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.paused
+}
+
+// waitUntil blocks until the Animation reaches the given offset, handling any pauses along the way
+func (p *Player) waitUntil(ctx context.Context, offset time.Duration) error {
+	for {
+		if p.isPaused() {
+			err := p.hold(ctx)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		timer := time.NewTimer(time.Until(p.base.Add(offset)))
+
+		select {
+		case <-timer.C:
+			return nil
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-p.changed:
+			timer.Stop()
+		}
+	}
+}
+
+// hold freezes the current transition, waits to be resumed, then finishes the transition
+func (p *Player) hold(ctx context.Context) error {
+	pausedAt := time.Now()
+	position := pausedAt.Sub(p.base)
+
+	// Without a previous Step the transition started from an unknown state, so it can't be frozen
+	if p.current != nil && p.prev != nil && position < p.current.At+p.current.Duration {
+		progress := float64(position-p.current.At) / float64(p.current.Duration)
+		frozen := *p.current
+		frozen.Duration = 0
+		frozen.Power = ""
+		frozen.Color = lerpColor(p.prev.Color, p.current.Color, progress)
+		frozen.Brightness = lerp(p.prev.Brightness, p.current.Brightness, progress)
+
+		err := p.send(frozen)
+		if err != nil {
+			return err
+		}
+	}
+
+	for p.isPaused() {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-p.changed:
+		}
+	}
+
+	p.base = p.base.Add(time.Since(pausedAt))
+
+	if p.current != nil && position < p.current.At+p.current.Duration {
+		remaining := *p.current
+		remaining.Duration = p.current.At + p.current.Duration - position
+		return p.send(remaining)
+	}
+
+	return nil
+}
+
+func (p *Player) send(step Step) error {
+	_, err := filament.SetState(&p.client, p.animation.Selector, step.Payload())
+	return err
+}
+
+// segmentsFor returns the fewest linear segments that approximate ease within tolerance,
+// without splitting the transition into calls closer together than minInterval
+func segmentsFor(ease Easing, length, minInterval time.Duration, tolerance float64) int {
+	if ease == nil || length <= 0 {
+		return 1
+	}
+
+	limit := int(length / minInterval)
+	if limit > maxSegments {
+		limit = maxSegments
+	}
+
+	for segments := 1; segments < limit; segments++ {
+		if easingError(ease, segments) <= tolerance {
+			return segments
+		}
+	}
+
+	if limit < 1 {
+		return 1
+	}
+	return limit
+}
+
+// easingError returns the largest distance between ease and its linear approximation
+func easingError(ease Easing, segments int) float64 {
+	var worst float64
+
+	for s := 0; s < segments; s++ {
+		t0 := float64(s) / float64(segments)
+		t1 := float64(s+1) / float64(segments)
+		v0, v1 := ease(t0), ease(t1)
+
+		for i := 1; i < 8; i++ {
+			f := float64(i) / 8
+			t := t0 + (t1-t0)*f
+			worst = math.Max(worst, math.Abs(ease(t)-lerp(v0, v1, f)))
+		}
+	}
+
+	return worst
+}
+
+func keyframeStep(keyframe Keyframe, at, duration time.Duration, power string) Step {
+	return Step{
+		At:         at,
+		Duration:   duration,
+		Power:      power,
+		Color:      keyframe.Color,
+		Brightness: keyframe.Brightness,
+	}
+}
+
+func lerp(from, to, progress float64) float64 {
+	return from + (to-from)*progress
+}
+
+// lerpColor returns the color progress of the way between two Keyframes' colors. Without a color to
+// go to the lights' color is left alone, and without one to come from LIFX fades from whatever it is.
+func lerpColor(from, to *device.Color, progress float64) *device.Color {
+	if to == nil || from == nil {
+		return to
+	}
+
+	color := filament.BlendColors(*from, *to, progress)
+	color.Kelvin = to.Kelvin
+
+	// A zero Kelvin means "leave it alone", so only interpolate when both ends set one
+	if from.Kelvin > 0 && to.Kelvin > 0 {
+		color.Kelvin = math.Floor(lerp(from.Kelvin, to.Kelvin, progress) + 0.5)
+	}
+
+	return &color
+}
@@ -0,0 +1,214 @@
+package animation_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/panicpanicpanic/filament/animation"
+	"github.com/panicpanicpanic/filament/device"
+	"github.com/panicpanicpanic/filament/lifx"
+)
+
+func TestAnimationPlan(t *testing.T) {
+	t.Run("when every transition is linear", func(t *testing.T) {
+		anim := animation.Animation{
+			Keyframes: []animation.Keyframe{
+				{At: 0, Power: "on", Brightness: 0.2},
+				{At: 5 * time.Second, Brightness: 1},
+				{At: 10 * time.Second, Power: "off", Brightness: 0},
+			},
+		}
+
+		steps := anim.Plan()
+		if len(steps) != 3 {
+			t.Fatalf("it should have planned one call per keyframe, got %d", len(steps))
+		}
+		if steps[1].At != 0 || steps[1].Duration != 5*time.Second {
+			t.Errorf("it should have let LIFX interpolate the whole transition, got %v over %v", steps[1].At, steps[1].Duration)
+		}
+	})
+
+	t.Run("when a transition is eased", func(t *testing.T) {
+		anim := animation.Animation{
+			Keyframes: []animation.Keyframe{
+				{At: 0, Brightness: 0, Color: &device.Color{Hue: 0, Saturation: 1}},
+				{At: 10 * time.Second, Power: "off", Brightness: 1, Color: &device.Color{Hue: 120, Saturation: 1}, Easing: animation.EaseInOut},
+			},
+		}
+
+		steps := anim.Plan()
+		if len(steps) < 3 {
+			t.Fatalf("it should have split the eased transition into several calls, got %d", len(steps))
+		}
+		if len(steps) > 1+int(10*time.Second/(500*time.Millisecond)) {
+			t.Errorf("it should not have planned calls faster than the rate limit, got %d", len(steps))
+		}
+
+		last := steps[len(steps)-1]
+		if last.Brightness != 1 || last.Color.Hue != 120 {
+			t.Errorf("it should have finished on the keyframe's state, got %+v", last)
+		}
+		for _, step := range steps[:len(steps)-1] {
+			if step.Power == "off" {
+				t.Errorf("it should only turn the lights off at the end of the transition, got %+v", step)
+			}
+		}
+	})
+
+	t.Run("when a transition crosses 0 degrees", func(t *testing.T) {
+		anim := animation.Animation{
+			Keyframes: []animation.Keyframe{
+				{At: 0, Color: &device.Color{Hue: 350, Saturation: 1}},
+				{At: 10 * time.Second, Color: &device.Color{Hue: 10, Saturation: 1}, Easing: animation.EaseIn},
+			},
+		}
+
+		for _, step := range anim.Plan() {
+			if hue := step.Color.Hue; hue > 10 && hue < 350 {
+				t.Errorf("it should have gone the short way round through red, got hue %v", hue)
+			}
+		}
+	})
+
+	t.Run("when keyframes only change brightness", func(t *testing.T) {
+		anim := animation.Animation{
+			Keyframes: []animation.Keyframe{
+				{At: 0, Brightness: 0.2},
+				{At: 10 * time.Second, Brightness: 1, Easing: animation.EaseInOut},
+			},
+		}
+
+		for _, step := range anim.Plan() {
+			if _, ok := step.Payload()["color"]; ok {
+				t.Errorf("it should have left the color alone, got %v", step.Payload())
+			}
+		}
+	})
+
+	t.Run("when keyframes are out of order", func(t *testing.T) {
+		anim := animation.Animation{
+			Keyframes: []animation.Keyframe{
+				{At: 5 * time.Second},
+				{At: time.Second},
+			},
+		}
+
+		if anim.Validate() == nil {
+			t.Errorf("it should have rejected keyframes that go back in time")
+		}
+	})
+}
+
+func TestPlayer(t *testing.T) {
+	var mutex sync.Mutex
+	var payloads []map[string]interface{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&payload)
+
+		mutex.Lock()
+		payloads = append(payloads, payload)
+		mutex.Unlock()
+
+		w.WriteHeader(http.StatusMultiStatus)
+		w.Write([]byte(`{"results": []}`))
+	}))
+	defer server.Close()
+
+	client := &lifx.Client{AccessToken: "someRandomToken", BaseURL: server.URL}
+	sent := func() []map[string]interface{} {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return append([]map[string]interface{}(nil), payloads...)
+	}
+	reset := func() {
+		mutex.Lock()
+		payloads = nil
+		mutex.Unlock()
+	}
+
+	anim := animation.Animation{
+		Selector:    "label:Desk",
+		MinInterval: time.Millisecond,
+		Keyframes: []animation.Keyframe{
+			{At: 0, Power: "on", Brightness: 0},
+			{At: 400 * time.Millisecond, Brightness: 1},
+		},
+	}
+
+	t.Run("when the animation plays to the end", func(t *testing.T) {
+		reset()
+		start := time.Now()
+
+		err := animation.Play(context.Background(), client, anim)
+		if err != nil {
+			t.Fatalf("it should have finished, got %s", err)
+		}
+		if elapsed := time.Since(start); elapsed < 400*time.Millisecond {
+			t.Errorf("it should have lasted the length of the animation, took %s", elapsed)
+		}
+		if calls := sent(); len(calls) != 2 || calls[1]["brightness"] != 1.0 || calls[1]["duration"] != 0.4 {
+			t.Errorf("it should have sent one call per keyframe, got %v", calls)
+		}
+	})
+
+	t.Run("when ctx is cancelled", func(t *testing.T) {
+		reset()
+		looping := anim
+		looping.Loop = true
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		err := animation.Play(ctx, client, looping)
+		if err != context.DeadlineExceeded {
+			t.Errorf("it should have stopped with the ctx error, got %v", err)
+		}
+	})
+
+	t.Run("when the animation is paused and resumed", func(t *testing.T) {
+		reset()
+		player, err := animation.NewPlayer(client, anim)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		done := make(chan error, 1)
+		start := time.Now()
+		go func() { done <- player.Run(context.Background()) }()
+
+		time.Sleep(100 * time.Millisecond)
+		player.Pause()
+		time.Sleep(200 * time.Millisecond)
+
+		calls := sent()
+		if len(calls) != 3 || calls[2]["duration"] != 0.0 {
+			t.Fatalf("it should have frozen the lights with an instant call, got %v", calls)
+		}
+		if brightness := calls[2]["brightness"].(float64); brightness <= 0 || brightness >= 0.6 {
+			t.Errorf("it should have frozen part way through the transition, got %v", brightness)
+		}
+
+		player.Resume()
+		if err := <-done; err != nil {
+			t.Fatalf("it should have finished, got %s", err)
+		}
+
+		calls = sent()
+		if len(calls) != 4 || calls[3]["brightness"] != 1.0 {
+			t.Fatalf("it should have finished the transition after resuming, got %v", calls)
+		}
+		if remaining := calls[3]["duration"].(float64); remaining <= 0 || remaining >= 0.4 {
+			t.Errorf("it should have only used the remaining time, got %v", remaining)
+		}
+		if elapsed := time.Since(start); elapsed < 600*time.Millisecond {
+			t.Errorf("it should have pushed the end back by the pause, took %s", elapsed)
+		}
+	})
+}
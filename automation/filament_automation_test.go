@@ -0,0 +1,278 @@
+package automation_test
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/panicpanicpanic/filament/automation"
+	"github.com/panicpanicpanic/filament/device"
+	"github.com/panicpanicpanic/filament/lifx"
+	"github.com/panicpanicpanic/filament/watcher"
+)
+
+const rules = `
+rules:
+  - name: build-failed
+    trigger: {webhook: build}
+    when: {payload: {build.status: failed}}
+    cooldown: 10m
+    actions:
+      - do: pulse
+        selector: "group:Team Room"
+        payload: {color: red, cycles: 5, persist: true}
+  - name: build-recovered
+    trigger: {webhook: build}
+    when: {payload: {build.status: passed}}
+    actions:
+      - {do: set_state, selector: "group:Team Room", payload: {color: white}}
+  - name: morning
+    trigger: {at: "07:30"}
+    actions:
+      - {do: scene, scene: abc-123}
+  - name: porch-on
+    disabled: true
+    trigger: {change: {selector: "label:Door", field: power, to: "on"}}
+    when: {after: "18:00", before: "06:00"}
+    actions:
+      - {do: toggle, selector: "label:Porch"}
+`
+
+type recorder struct {
+	mutex    sync.Mutex
+	requests []string
+}
+
+func (r *recorder) calls() []string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return append([]string(nil), r.requests...)
+}
+
+func newEngine(t *testing.T) (*automation.Engine, *automation.FakeClock, *recorder) {
+	return newEngineWith(t, rules, time.Date(2026, 3, 1, 7, 0, 0, 0, time.UTC))
+}
+
+func newEngineWith(t *testing.T, rules string, start time.Time) (*automation.Engine, *automation.FakeClock, *recorder) {
+	path := filepath.Join(t.TempDir(), "rules.yaml")
+	if err := ioutil.WriteFile(path, []byte(rules), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := automation.Load(path)
+	if err != nil {
+		t.Fatalf("it should have loaded the rules, got %s", err)
+	}
+
+	record := &recorder{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		record.mutex.Lock()
+		record.requests = append(record.requests, r.Method+" "+r.URL.Path+" "+string(body))
+		record.mutex.Unlock()
+		w.Write([]byte(`{"results": []}`))
+	}))
+	t.Cleanup(server.Close)
+
+	clock := automation.NewFakeClock(start)
+	engine := automation.New(&lifx.Client{AccessToken: "someRandomToken", BaseURL: server.URL}, loaded)
+	engine.Clock = clock
+	engine.Secret = "someRandomSecret"
+
+	return engine, clock, record
+}
+
+func TestWebhookRules(t *testing.T) {
+	engine, clock, record := newEngine(t)
+	ctx := context.Background()
+	failed := map[string]interface{}{"build": map[string]interface{}{"status": "failed"}}
+
+	t.Run("when the build fails", func(t *testing.T) {
+		fired, err := engine.Webhook(ctx, "build", failed)
+		if err != nil || len(fired) != 1 || fired[0] != "build-failed" {
+			t.Fatalf("it should have fired build-failed, got %v %v", fired, err)
+		}
+
+		calls := record.calls()
+		if len(calls) != 1 || !strings.HasPrefix(calls[0], "POST /lights/group:Team Room/effects/pulse") || !strings.Contains(calls[0], `"color":"red"`) {
+			t.Errorf("it should have pulsed the team room red, got %v", calls)
+		}
+	})
+
+	t.Run("when the build fails again within the cooldown", func(t *testing.T) {
+		clock.Advance(5 * time.Minute)
+		if fired, _ := engine.Webhook(ctx, "build", failed); len(fired) != 0 {
+			t.Errorf("it should have been cooling down, got %v", fired)
+		}
+
+		clock.Advance(5 * time.Minute)
+		if fired, _ := engine.Webhook(ctx, "build", failed); len(fired) != 1 {
+			t.Errorf("it should have fired once the cooldown passed, got %v", fired)
+		}
+	})
+
+	t.Run("when the build recovers over HTTP", func(t *testing.T) {
+		request := httptest.NewRequest("POST", "/hooks/build", strings.NewReader(`{"build": {"status": "passed"}}`))
+		request.Header.Set("Authorization", "Bearer someRandomSecret")
+		response := httptest.NewRecorder()
+		engine.ServeHTTP(response, request)
+
+		var body struct{ Fired []string }
+		json.NewDecoder(response.Body).Decode(&body)
+		if response.Code != http.StatusOK || len(body.Fired) != 1 || body.Fired[0] != "build-recovered" {
+			t.Errorf("it should have fired build-recovered, got %d %v", response.Code, body.Fired)
+		}
+	})
+
+	t.Run("when the HTTP request has no valid token", func(t *testing.T) {
+		for _, header := range []string{"", "someRandomSecret", "Bearer wrongSecret"} {
+			request := httptest.NewRequest("POST", "/rules/build-recovered/disable", nil)
+			if header != "" {
+				request.Header.Set("Authorization", header)
+			}
+			response := httptest.NewRecorder()
+			engine.ServeHTTP(response, request)
+
+			if response.Code != http.StatusUnauthorized || !engine.Enabled("build-recovered") {
+				t.Errorf("it should have refused %q, got %d", header, response.Code)
+			}
+		}
+	})
+
+	t.Run("when a rule is disabled over HTTP", func(t *testing.T) {
+		request := httptest.NewRequest("POST", "/rules/build-recovered/disable", nil)
+		request.Header.Set("Authorization", "Bearer someRandomSecret")
+		response := httptest.NewRecorder()
+		engine.ServeHTTP(response, request)
+		if response.Code != http.StatusNoContent || engine.Enabled("build-recovered") {
+			t.Fatalf("it should have disabled the rule, got %d", response.Code)
+		}
+
+		fired, _ := engine.Webhook(ctx, "build", map[string]interface{}{"build": map[string]interface{}{"status": "passed"}})
+		if len(fired) != 0 {
+			t.Errorf("it should not have fired a disabled rule, got %v", fired)
+		}
+	})
+}
+
+func TestScheduleRules(t *testing.T) {
+	engine, clock, record := newEngine(t)
+	ctx := context.Background()
+
+	if fired, _ := engine.Tick(ctx); len(fired) != 0 {
+		t.Errorf("it should not have fired before 07:30, got %v", fired)
+	}
+
+	clock.Advance(31 * time.Minute)
+	if fired, _ := engine.Tick(ctx); len(fired) != 1 || fired[0] != "morning" {
+		t.Errorf("it should have fired morning, got %v", fired)
+	}
+	if calls := record.calls(); len(calls) != 1 || !strings.HasPrefix(calls[0], "PUT /scenes/scene_id:abc-123/activate") {
+		t.Errorf("it should have activated the scene, got %v", calls)
+	}
+
+	clock.Advance(time.Hour)
+	if fired, _ := engine.Tick(ctx); len(fired) != 0 {
+		t.Errorf("it should only fire once a day, got %v", fired)
+	}
+
+	clock.Advance(24 * time.Hour)
+	if fired, _ := engine.Tick(ctx); len(fired) != 1 {
+		t.Errorf("it should have fired again the next day, got %v", fired)
+	}
+}
+
+func TestScheduleRulesStartedLate(t *testing.T) {
+	engine, clock, _ := newEngineWith(t, rules, time.Date(2026, 3, 1, 22, 0, 0, 0, time.UTC))
+	ctx := context.Background()
+
+	if fired, _ := engine.Tick(ctx); len(fired) != 0 {
+		t.Errorf("it should not have fired a time that had already passed, got %v", fired)
+	}
+
+	clock.Advance(9*time.Hour + 31*time.Minute)
+	if fired, _ := engine.Tick(ctx); len(fired) != 1 || fired[0] != "morning" {
+		t.Errorf("it should have fired morning the next day, got %v", fired)
+	}
+}
+
+func TestScheduleRulesBlocked(t *testing.T) {
+	blocked := `
+rules:
+  - name: lamp-off
+    trigger: {at: "22:00"}
+    when: {selector: "label:Lamp", power: "on"}
+    actions:
+      - {do: toggle, selector: "label:Lamp"}
+`
+	engine, clock, _ := newEngineWith(t, blocked, time.Date(2026, 3, 1, 21, 0, 0, 0, time.UTC))
+	ctx := context.Background()
+	lamp := device.Device{ID: "1", Label: "Lamp", Power: "off"}
+
+	engine.Observe(ctx, []device.Device{lamp}, nil)
+	engine.Tick(ctx)
+
+	clock.Advance(61 * time.Minute)
+	if fired, _ := engine.Tick(ctx); len(fired) != 0 {
+		t.Errorf("it should not have fired while the lamp was off, got %v", fired)
+	}
+
+	lamp.Power = "on"
+	engine.Observe(ctx, []device.Device{lamp}, nil)
+	clock.Advance(time.Minute)
+	if fired, _ := engine.Tick(ctx); len(fired) != 1 || fired[0] != "lamp-off" {
+		t.Errorf("it should have fired once the lamp was on, got %v", fired)
+	}
+
+	clock.Advance(time.Minute)
+	if fired, _ := engine.Tick(ctx); len(fired) != 0 {
+		t.Errorf("it should only fire once a day, got %v", fired)
+	}
+}
+
+func TestChangeRules(t *testing.T) {
+	engine, clock, record := newEngine(t)
+	ctx := context.Background()
+	clock.Advance(13 * time.Hour)
+
+	off := device.Device{ID: "1", Label: "Door", Power: "off"}
+	on := off
+	on.Power = "on"
+
+	t.Run("when the rule is disabled", func(t *testing.T) {
+		engine.Observe(ctx, []device.Device{on}, watcher.Diff([]device.Device{off}, []device.Device{on}))
+		if calls := record.calls(); len(calls) != 0 {
+			t.Errorf("it should not have run, got %v", calls)
+		}
+	})
+
+	t.Run("when the rule is enabled", func(t *testing.T) {
+		engine.Enable("porch-on", true)
+
+		engine.Observe(ctx, []device.Device{on}, watcher.Diff(nil, []device.Device{on}))
+		if calls := record.calls(); len(calls) != 0 {
+			t.Errorf("it should not have fired for a newly seen light, got %v", calls)
+		}
+
+		engine.Observe(ctx, []device.Device{on}, watcher.Diff([]device.Device{off}, []device.Device{on}))
+		if calls := record.calls(); len(calls) != 1 || !strings.HasPrefix(calls[0], "POST /lights/label:Porch/toggle") {
+			t.Errorf("it should have toggled the porch, got %v", calls)
+		}
+	})
+}
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.yaml")
+	ioutil.WriteFile(path, []byte("rules:\n  - name: broken\n    trigger: {webhook: x, every: 1m}\n    actions: [{do: toggle, selector: all}]\n"), 0600)
+
+	if _, err := automation.Load(path); err == nil {
+		t.Errorf("it should have rejected a rule with two triggers")
+	}
+}
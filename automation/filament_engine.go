@@ -0,0 +1,333 @@
+package automation
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/panicpanicpanic/filament"
+	"github.com/panicpanicpanic/filament/device"
+	"github.com/panicpanicpanic/filament/lifx"
+	"github.com/panicpanicpanic/filament/watcher"
+)
+
+// Clock tells an Engine the time, so schedules and cooldowns can be tested with a FakeClock
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// FakeClock is a Clock that only moves when told to
+type FakeClock struct {
+	mutex sync.Mutex
+	now   time.Time
+}
+
+// NewFakeClock returns a FakeClock set to now
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+// Now returns the FakeClock's time
+func (c *FakeClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.now
+}
+
+// Advance moves the FakeClock forward by d
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// Engine runs Rules against the Events it is given: webhooks, schedule ticks and light changes
+type Engine struct {
+	Client *lifx.Client
+	Clock  Clock
+
+	// Secret has to be sent as a bearer token with every request to ServeHTTP, which refuses them all
+	// while it is empty
+	Secret string
+
+	// OnError, if set, is called when a Rule's Action fails
+	OnError func(rule string, err error)
+
+	mutex   sync.Mutex
+	rules   []*Rule
+	state   map[string]*ruleState
+	devices []device.Device
+}
+
+// ruleState is what the Engine remembers about a Rule between Events
+type ruleState struct {
+	enabled   bool
+	fired     time.Time
+	scheduled time.Time
+	day       string
+	ticked    bool
+}
+
+// New returns an Engine running rules with client
+func New(client *lifx.Client, rules []*Rule) *Engine {
+	e := &Engine{Client: client, rules: rules, state: map[string]*ruleState{}}
+	for _, rule := range rules {
+		e.state[rule.Name] = &ruleState{enabled: !rule.Disabled}
+	}
+	return e
+}
+
+// Enable turns the named Rule on or off
+func (e *Engine) Enable(name string, enabled bool) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	state, ok := e.state[name]
+	if !ok {
+		return fmt.Errorf("no rule named %q", name)
+	}
+
+	state.enabled = enabled
+	return nil
+}
+
+// Enabled reports whether the named Rule is on
+func (e *Engine) Enabled(name string) bool {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	state, ok := e.state[name]
+	return ok && state.enabled
+}
+
+// Webhook fires the Rules waiting on the named webhook, returning the names of those that ran
+func (e *Engine) Webhook(ctx context.Context, name string, payload map[string]interface{}) ([]string, error) {
+	event := Event{Time: e.now(), Webhook: name, Payload: payload}
+	return e.fire(ctx, event, func(rule *Rule, _ *ruleState) bool {
+		return rule.Trigger.Webhook == name
+	}, nil)
+}
+
+// Tick fires the Rules whose schedule is due. Run calls it every second; tests can call it after
+// advancing a FakeClock. A Rule whose Conditions don't hold stays due, and fires on the first Tick
+// they do.
+func (e *Engine) Tick(ctx context.Context) ([]string, error) {
+	event := Event{Time: e.now()}
+	day := event.Time.Format("2006-01-02")
+
+	due := func(rule *Rule, state *ruleState) bool {
+		first := !state.ticked
+		state.ticked = true
+
+		if rule.Trigger.Every > 0 {
+			// The first tick starts the schedule rather than firing straight away
+			if first {
+				state.scheduled = event.Time
+				return false
+			}
+			return event.Time.Sub(state.scheduled) >= rule.Trigger.Every
+		}
+
+		if rule.Trigger.At != "" {
+			at, _ := minutes(rule.Trigger.At)
+			if event.Time.Hour()*60+event.Time.Minute() < at || state.day == day {
+				return false
+			}
+
+			// An Engine started after today's time waits for tomorrow rather than firing late
+			if first {
+				state.day = day
+				return false
+			}
+			return true
+		}
+
+		return false
+	}
+
+	return e.fire(ctx, event, due, func(rule *Rule, state *ruleState) {
+		state.scheduled = event.Time
+		state.day = day
+	})
+}
+
+// Observe fires the Rules waiting on light changes, and remembers devices for Conditions that
+// check a light's power
+func (e *Engine) Observe(ctx context.Context, devices []device.Device, changes []watcher.Change) {
+	e.mutex.Lock()
+	e.devices = devices
+	e.mutex.Unlock()
+
+	for i := range changes {
+		change := changes[i]
+		event := Event{Time: e.now(), Change: &change}
+		e.fire(ctx, event, func(rule *Rule, _ *ruleState) bool {
+			return rule.Trigger.Change != nil && rule.Trigger.Change.matchesChange(change)
+		}, nil)
+	}
+}
+
+// Run ticks the schedule every second and, if w is set, watches the lights with it until ctx is done
+func (e *Engine) Run(ctx context.Context, w *watcher.Watcher) error {
+	if w != nil {
+		go w.Run(ctx, func(devices []device.Device, changes []watcher.Change) {
+			e.Observe(ctx, devices, changes)
+		})
+	}
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			e.Tick(ctx)
+		}
+	}
+}
+
+// fire runs the enabled Rules that triggered matches, whose Conditions hold and that aren't cooling
+// down, calling record, if set, for each one that runs. Every Rule's Actions are attempted; the first
+// error is returned.
+func (e *Engine) fire(ctx context.Context, event Event, triggered func(*Rule, *ruleState) bool, record func(*Rule, *ruleState)) ([]string, error) {
+	var ready []*Rule
+
+	e.mutex.Lock()
+	for _, rule := range e.rules {
+		state := e.state[rule.Name]
+		if !triggered(rule, state) || !state.enabled || !rule.When.holds(event, e.devices) {
+			continue
+		}
+		if !state.fired.IsZero() && event.Time.Sub(state.fired) < rule.Cooldown {
+			continue
+		}
+
+		state.fired = event.Time
+		if record != nil {
+			record(rule, state)
+		}
+		ready = append(ready, rule)
+	}
+	e.mutex.Unlock()
+
+	var fired []string
+	var first error
+	for _, rule := range ready {
+		fired = append(fired, rule.Name)
+
+		for _, action := range rule.Actions {
+			err := e.run(ctx, action)
+			if err == nil {
+				continue
+			}
+
+			err = fmt.Errorf("rule %q: %s", rule.Name, err.Error())
+			if first == nil {
+				first = err
+			}
+			if e.OnError != nil {
+				e.OnError(rule.Name, err)
+			}
+		}
+	}
+
+	return fired, first
+}
+
+// run makes an Action's call with a copy of the Engine's client
+func (e *Engine) run(ctx context.Context, action Action) error {
+	client := *e.Client
+	client.Context = ctx
+
+	var err error
+	switch action.Do {
+	case "set_state":
+		_, err = filament.SetState(&client, action.Selector, action.Payload)
+	case "pulse":
+		_, err = filament.PulseEffect(&client, action.Selector, action.Payload)
+	case "scene":
+		_, err = filament.ActivateScene(&client, action.Scene, action.Payload)
+	case "toggle":
+		_, err = filament.TogglePower(&client, action.Selector)
+	default:
+		err = fmt.Errorf("unknown action %q", action.Do)
+	}
+
+	return err
+}
+
+// ServeHTTP accepts webhooks at POST /hooks/<name> with an optional JSON object body, and turns
+// Rules on and off at POST /rules/<name>/enable and /rules/<name>/disable. Every request needs an
+// "Authorization: Bearer <Secret>" header.
+func (e *Engine) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !e.authorized(r) {
+		http.Error(w, "a valid bearer token is required", http.StatusUnauthorized)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case len(parts) == 2 && parts[0] == "hooks":
+		payload := map[string]interface{}{}
+		if r.ContentLength != 0 {
+			err := json.NewDecoder(r.Body).Decode(&payload)
+			if err != nil {
+				http.Error(w, "invalid JSON payload: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+
+		fired, err := e.Webhook(r.Context(), parts[1], payload)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"fired": fired})
+	case len(parts) == 3 && parts[0] == "rules" && (parts[2] == "enable" || parts[2] == "disable"):
+		err := e.Enable(parts[1], parts[2] == "enable")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (e *Engine) authorized(r *http.Request) bool {
+	header := r.Header.Get("Authorization")
+	if e.Secret == "" || !strings.HasPrefix(header, "Bearer ") {
+		return false
+	}
+
+	token := strings.TrimPrefix(header, "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(token), []byte(e.Secret)) == 1
+}
+
+func (e *Engine) now() time.Time {
+	if e.Clock == nil {
+		return systemClock{}.Now()
+	}
+	return e.Clock.Now()
+}
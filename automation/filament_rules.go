@@ -0,0 +1,296 @@
+package automation
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/panicpanicpanic/filament/device"
+	"github.com/panicpanicpanic/filament/watcher"
+	"gopkg.in/yaml.v2"
+)
+
+// Rule maps a Trigger to Actions. A rules file looks like
+//
+//	rules:
+//	  - name: build-failed
+//	    trigger: {webhook: build}
+//	    when: {payload: {status: failed}}
+//	    cooldown: 10m
+//	    actions:
+//	      - {do: pulse, selector: "group:Team Room", payload: {color: red, cycles: 5, persist: true}}
+type Rule struct {
+	Name     string        `yaml:"name"`
+	Disabled bool          `yaml:"disabled"`
+	Trigger  Trigger       `yaml:"trigger"`
+	When     Condition     `yaml:"when"`
+	Cooldown time.Duration `yaml:"cooldown"`
+	Actions  []Action      `yaml:"actions"`
+}
+
+// Trigger is what makes a Rule fire. Exactly one of its fields should be set.
+type Trigger struct {
+	// Webhook fires the Rule when the named webhook is posted to
+	Webhook string `yaml:"webhook"`
+
+	// Every fires the Rule each time the interval passes
+	Every time.Duration `yaml:"every"`
+
+	// At fires the Rule once a day at the given "15:04" time
+	At string `yaml:"at"`
+
+	// Change fires the Rule when a light's state changes
+	Change *ChangeTrigger `yaml:"change"`
+}
+
+// ChangeTrigger matches light state changes reported by a watcher.Watcher
+type ChangeTrigger struct {
+	// Selector limits the lights that can fire the Rule, defaulting to all of them
+	Selector string `yaml:"selector"`
+
+	// Field is the field that has to change, e.g. "power" or "connected". Any change fires the Rule
+	// when it's empty.
+	Field string `yaml:"field"`
+
+	// To, if set, is the value Field has to change to, e.g. "on" or "false"
+	To string `yaml:"to"`
+}
+
+// Condition has to hold for a triggered Rule to run its Actions. Unset fields always hold.
+type Condition struct {
+	// Payload is the webhook payload fields that have to match, using dots for nested fields
+	Payload map[string]string `yaml:"payload"`
+
+	// After and Before limit the Rule to a "15:04" time window, which can wrap past midnight
+	After  string `yaml:"after"`
+	Before string `yaml:"before"`
+
+	// Selector and Power require a light matching Selector to be "on" or "off"
+	Selector string `yaml:"selector"`
+	Power    string `yaml:"power"`
+}
+
+// Action is a call made when a Rule runs. Do is one of "set_state", "pulse", "scene" or "toggle".
+type Action struct {
+	Do       string                 `yaml:"do"`
+	Selector string                 `yaml:"selector"`
+	Scene    string                 `yaml:"scene"`
+	Payload  map[string]interface{} `yaml:"payload"`
+}
+
+// Event is what set a Rule off
+type Event struct {
+	Time    time.Time
+	Webhook string
+	Payload map[string]interface{}
+	Change  *watcher.Change
+}
+
+// Load reads the Rules in the YAML file at path, which holds a list under "rules"
+func Load(path string) ([]*Rule, error) {
+	body, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf(err.Error())
+	}
+
+	var file struct {
+		Rules []*Rule `yaml:"rules"`
+	}
+	err = yaml.UnmarshalStrict(body, &file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err.Error())
+	}
+
+	names := map[string]bool{}
+	for _, rule := range file.Rules {
+		err = rule.Validate()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", path, err.Error())
+		}
+		if names[rule.Name] {
+			return nil, fmt.Errorf("%s: rule %q is defined twice", path, rule.Name)
+		}
+		names[rule.Name] = true
+
+		// YAML decodes nested maps with interface{} keys, which can't be sent as JSON
+		for i := range rule.Actions {
+			rule.Actions[i].Payload = stringKeys(rule.Actions[i].Payload).(map[string]interface{})
+		}
+	}
+
+	return file.Rules, nil
+}
+
+// Validate checks the Rule is complete enough to run
+func (r *Rule) Validate() error {
+	if r.Name == "" {
+		return fmt.Errorf("rule has no name")
+	}
+
+	triggers := 0
+	for _, set := range []bool{r.Trigger.Webhook != "", r.Trigger.Every > 0, r.Trigger.At != "", r.Trigger.Change != nil} {
+		if set {
+			triggers++
+		}
+	}
+	if triggers != 1 {
+		return fmt.Errorf("rule %q should have exactly one trigger, has %d", r.Name, triggers)
+	}
+
+	for _, clock := range []string{r.Trigger.At, r.When.After, r.When.Before} {
+		if _, err := minutes(clock); clock != "" && err != nil {
+			return fmt.Errorf("rule %q: %s", r.Name, err.Error())
+		}
+	}
+
+	if len(r.Actions) == 0 {
+		return fmt.Errorf("rule %q has no actions", r.Name)
+	}
+	for _, action := range r.Actions {
+		switch action.Do {
+		case "set_state", "pulse", "toggle":
+			if action.Selector == "" {
+				return fmt.Errorf("rule %q: %s needs a selector", r.Name, action.Do)
+			}
+		case "scene":
+			if action.Scene == "" {
+				return fmt.Errorf("rule %q: scene needs a scene UUID", r.Name)
+			}
+		default:
+			return fmt.Errorf("rule %q: unknown action %q", r.Name, action.Do)
+		}
+	}
+
+	return nil
+}
+
+// matchesChange reports whether change is one the Rule's ChangeTrigger is waiting for
+func (t *ChangeTrigger) matchesChange(change watcher.Change) bool {
+	if change.After == nil {
+		return false
+	}
+
+	selector := t.Selector
+	if selector == "" {
+		selector = "all"
+	}
+	if !change.After.Matches(selector) {
+		return false
+	}
+
+	if t.Field == "" {
+		return true
+	}
+
+	// Lights seen for the first time have no Fields, so they never fire a Rule waiting on one
+	changed := false
+	for _, field := range change.Fields {
+		changed = changed || field == t.Field
+	}
+	if !changed {
+		return false
+	}
+
+	return t.To == "" || fieldValue(*change.After, t.Field) == t.To
+}
+
+// holds reports whether the Condition is met for event, given the last known lights
+func (c Condition) holds(event Event, devices []device.Device) bool {
+	for path, expected := range c.Payload {
+		value, ok := lookup(event.Payload, strings.Split(path, "."))
+		if !ok || fmt.Sprint(value) != expected {
+			return false
+		}
+	}
+
+	if c.After != "" || c.Before != "" {
+		now := event.Time.Hour()*60 + event.Time.Minute()
+		after, _ := minutes(c.After)
+		before, err := minutes(c.Before)
+		if err != nil || c.Before == "" {
+			before = 24 * 60
+		}
+
+		inside := now >= after && now < before
+		if after > before {
+			inside = now >= after || now < before
+		}
+		if !inside {
+			return false
+		}
+	}
+
+	if c.Selector != "" {
+		found := false
+		for _, d := range device.Filter(devices, c.Selector) {
+			found = found || c.Power == "" || d.Power == c.Power
+		}
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+func fieldValue(d device.Device, field string) string {
+	switch field {
+	case "label":
+		return d.Label
+	case "connected":
+		return strconv.FormatBool(d.Connected)
+	case "power":
+		return d.Power
+	case "brightness":
+		return strconv.FormatFloat(d.Brightness, 'f', -1, 64)
+	case "color":
+		return d.Color.String()
+	}
+	return ""
+}
+
+func lookup(payload map[string]interface{}, path []string) (interface{}, bool) {
+	value, ok := payload[path[0]]
+	if !ok || len(path) == 1 {
+		return value, ok
+	}
+
+	nested, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+	return lookup(nested, path[1:])
+}
+
+// minutes parses a "15:04" time into minutes past midnight
+func minutes(clock string) (int, error) {
+	parsed, err := time.Parse("15:04", clock)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", clock)
+	}
+	return parsed.Hour()*60 + parsed.Minute(), nil
+}
+
+func stringKeys(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[interface{}]interface{}:
+		converted := make(map[string]interface{}, len(value))
+		for key, nested := range value {
+			converted[fmt.Sprint(key)] = stringKeys(nested)
+		}
+		return converted
+	case map[string]interface{}:
+		for key, nested := range value {
+			value[key] = stringKeys(nested)
+		}
+		return value
+	case []interface{}:
+		for i, nested := range value {
+			value[i] = stringKeys(nested)
+		}
+		return value
+	}
+	return value
+}
@@ -0,0 +1,245 @@
+package filament
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/panicpanicpanic/filament/device"
+	"github.com/panicpanicpanic/filament/lifx"
+)
+
+// maxStates is the most states LIFX accepts in a single SetStates call
+const maxStates = 50
+
+// Batch builds a SetStates payload one selector at a time. States are sent in the order they were set,
+// so later states take precedence. Setting the same selector twice in a row merges the states, with
+// later fields winning, and batches too big for one call are split across several.
+//
+// Without Resolve, a selector set again after a different one is sent twice rather than merged, since
+// moving its fields ahead of the other selector's could change which state wins for lights both target.
+// Use Resolve to get a single state per light across the whole Batch.
+type Batch struct {
+	entries  []batchEntry
+	defaults map[string]interface{}
+	devices  []device.Device
+}
+
+type batchEntry struct {
+	selector string
+	state    map[string]interface{}
+}
+
+// BatchReport merges the results of every SetStates call a Batch made
+type BatchReport struct {
+	Results  []lifx.Result
+	Requests int
+	Errors   []error
+}
+
+// NewBatch returns an empty Batch
+func NewBatch() *Batch {
+	return &Batch{}
+}
+
+// Set adds a state for the lights within the given selector
+func (b *Batch) Set(selector string, state map[string]interface{}) *Batch {
+	// Merging into an earlier entry would move these fields ahead of the entries set since
+	if last := len(b.entries) - 1; last >= 0 && b.entries[last].selector == selector {
+		b.entries[last].state = merge(b.entries[last].state, state)
+		return b
+	}
+
+	b.entries = append(b.entries, batchEntry{selector: selector, state: merge(nil, state)})
+	return b
+}
+
+// Defaults sets fields, such as duration, applied to every state that doesn't set them itself
+func (b *Batch) Defaults(defaults map[string]interface{}) *Batch {
+	b.defaults = merge(b.defaults, defaults)
+	return b
+}
+
+// Resolve lets the Batch de-duplicate selectors that overlap, such as "label:Desk" and "group:Office".
+// Each light then gets a single state, merged from every entry targeting it in the order they were
+// set, and lights ending up with the same state share one selector.
+func (b *Batch) Resolve(devices []device.Device) *Batch {
+	b.devices = devices
+	return b
+}
+
+// Len returns how many states the Batch will send
+func (b *Batch) Len() int {
+	return len(b.states())
+}
+
+// Payloads returns the SetStates payloads the Batch compiles to, one per call
+func (b *Batch) Payloads() []map[string]interface{} {
+	var payloads []map[string]interface{}
+
+	states := b.states()
+	for start := 0; start < len(states); start += maxStates {
+		end := start + maxStates
+		if end > len(states) {
+			end = len(states)
+		}
+
+		payload := map[string]interface{}{
+			"states": states[start:end],
+		}
+		if len(b.defaults) > 0 {
+			payload["defaults"] = b.defaults
+		}
+
+		payloads = append(payloads, payload)
+	}
+
+	return payloads
+}
+
+// Send makes every SetStates call in the Batch. A failed call doesn't stop the rest from being sent;
+// its error is kept in the BatchReport and returned once they are all done.
+func (b *Batch) Send(client *lifx.Client) (BatchReport, error) {
+	var report BatchReport
+
+	for _, payload := range b.Payloads() {
+		report.Requests++
+
+		response, err := SetStates(client, payload)
+		if err != nil {
+			report.Errors = append(report.Errors, err)
+			continue
+		}
+
+		report.Results = append(report.Results, response.Results...)
+	}
+
+	return report, report.Err()
+}
+
+// Err returns an error combining every failed call, or nil if they all succeeded
+func (r BatchReport) Err() error {
+	var messages []string
+
+	for _, err := range r.Errors {
+		messages = append(messages, err.Error())
+	}
+
+	if len(messages) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%d of %d SetStates calls failed: %s", len(r.Errors), r.Requests, strings.Join(messages, "; "))
+}
+
+// Failed returns the results for lights that didn't report an "ok" status
+func (r BatchReport) Failed() []lifx.Result {
+	var failed []lifx.Result
+
+	for _, result := range r.Results {
+		if result.Status != "ok" {
+			failed = append(failed, result)
+		}
+	}
+
+	return failed
+}
+
+// Response returns the merged results as a single lifx.Response
+func (r BatchReport) Response() lifx.Response {
+	return lifx.Response{Results: r.Results}
+}
+
+// states returns the states to send, resolving overlapping selectors when the devices are known
+func (b *Batch) states() []map[string]interface{} {
+	var states []map[string]interface{}
+
+	if b.devices == nil {
+		for _, entry := range b.entries {
+			states = append(states, withSelector(entry.state, entry.selector))
+		}
+		return states
+	}
+
+	// Zone selectors and unknown lights can't be resolved, so they are sent as they are and in their
+	// place. The entries either side of them are resolved separately, so no state moves past them.
+	var run []batchEntry
+	for _, entry := range b.entries {
+		if strings.Contains(entry.selector, "|") || len(device.Filter(b.devices, entry.selector)) == 0 {
+			states = append(states, b.resolve(run)...)
+			states = append(states, withSelector(entry.state, entry.selector))
+			run = nil
+			continue
+		}
+
+		run = append(run, entry)
+	}
+
+	return append(states, b.resolve(run)...)
+}
+
+// resolve works out each light's final state from the entries, then groups the lights sharing a
+// state under one selector
+func (b *Batch) resolve(entries []batchEntry) []map[string]interface{} {
+	var states []map[string]interface{}
+	var order []string
+	perDevice := make(map[string]map[string]interface{})
+
+	for _, entry := range entries {
+		for _, d := range device.Filter(b.devices, entry.selector) {
+			if _, ok := perDevice[d.ID]; !ok {
+				order = append(order, d.ID)
+			}
+			perDevice[d.ID] = merge(perDevice[d.ID], entry.state)
+		}
+	}
+
+	var keys []string
+	grouped := make(map[string][]string)
+	representative := make(map[string]map[string]interface{})
+
+	for _, id := range order {
+		key := stateKey(perDevice[id])
+		if _, ok := grouped[key]; !ok {
+			keys = append(keys, key)
+			representative[key] = perDevice[id]
+		}
+		grouped[key] = append(grouped[key], "id:"+id)
+	}
+
+	for _, key := range keys {
+		states = append(states, withSelector(representative[key], strings.Join(grouped[key], ",")))
+	}
+
+	return states
+}
+
+func merge(into, from map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(into)+len(from))
+
+	for key, value := range into {
+		merged[key] = value
+	}
+	for key, value := range from {
+		merged[key] = value
+	}
+
+	return merged
+}
+
+func withSelector(state map[string]interface{}, selector string) map[string]interface{} {
+	withSelector := merge(state, nil)
+	withSelector["selector"] = selector
+	return withSelector
+}
+
+// stateKey returns a string that is the same for equal states
+func stateKey(state map[string]interface{}) string {
+	var fields []string
+
+	for key, value := range state {
+		fields = append(fields, fmt.Sprintf("%s=%v", key, value))
+	}
+	sort.Strings(fields)
+
+	return strings.Join(fields, "&")
+}
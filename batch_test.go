@@ -0,0 +1,78 @@
+package filament_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/panicpanicpanic/filament"
+	"github.com/panicpanicpanic/filament/device"
+	"github.com/panicpanicpanic/filament/lifx"
+)
+
+func TestBatch(t *testing.T) {
+	t.Run("when the same selector is set twice", func(t *testing.T) {
+		batch := filament.NewBatch().
+			Set("label:Desk", map[string]interface{}{"power": "on", "brightness": 0.5}).
+			Set("label:Desk", map[string]interface{}{"brightness": 1.0})
+
+		states := batch.Payloads()[0]["states"].([]map[string]interface{})
+		if len(states) != 1 || states[0]["power"] != "on" || states[0]["brightness"] != 1.0 {
+			t.Errorf("it should have merged both into one state, got %+v", states)
+		}
+	})
+
+	t.Run("when selectors overlap", func(t *testing.T) {
+		office := device.Group{Name: "Office"}
+		batch := filament.NewBatch().
+			Resolve([]device.Device{{ID: "1", Label: "Desk", Group: office}, {ID: "2", Label: "Shelf", Group: office}}).
+			Set("group:Office", map[string]interface{}{"power": "on"}).
+			Set("label:Desk", map[string]interface{}{"color": "red"})
+
+		states := batch.Payloads()[0]["states"].([]map[string]interface{})
+		if len(states) != 2 || states[0]["selector"] != "id:1" || states[0]["color"] != "red" || states[1]["selector"] != "id:2" {
+			t.Errorf("it should have given each light a single merged state, got %+v", states)
+		}
+	})
+
+	t.Run("when zones are set between overlapping selectors", func(t *testing.T) {
+		batch := filament.NewBatch().
+			Resolve([]device.Device{{ID: "1", Label: "Strip"}}).
+			Set("label:Strip", map[string]interface{}{"color": "red"}).
+			Set("id:1|0-5", map[string]interface{}{"color": "blue"}).
+			Set("label:Strip", map[string]interface{}{"brightness": 0.5})
+
+		states := batch.Payloads()[0]["states"].([]map[string]interface{})
+		if len(states) != 3 || states[0]["color"] != "red" || states[1]["selector"] != "id:1|0-5" || states[2]["brightness"] != 0.5 {
+			t.Errorf("it should have kept the zones between the states either side, got %+v", states)
+		}
+		if _, ok := states[2]["color"]; ok {
+			t.Errorf("it should not have repainted the zones red after they were set blue, got %+v", states[2])
+		}
+	})
+
+	t.Run("when a batch is larger than LIFX allows in one call", func(t *testing.T) {
+		var calls int
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			w.WriteHeader(http.StatusMultiStatus)
+			w.Write([]byte(`{"results": [{"id": "` + strconv.Itoa(calls) + `", "status": "ok"}]}`))
+		}))
+		defer server.Close()
+
+		batch := filament.NewBatch().Defaults(map[string]interface{}{"duration": 2})
+		for i := 0; i < 120; i++ {
+			batch.Set("id:"+strconv.Itoa(i), map[string]interface{}{"power": "on"})
+		}
+
+		report, err := batch.Send(&lifx.Client{AccessToken: "someRandomToken", BaseURL: server.URL})
+		if err != nil {
+			t.Fatalf("it should have sent every call, got %s", err)
+		}
+		if report.Requests != 3 || calls != 3 || len(report.Results) != 3 {
+			t.Errorf("it should have split the batch into 3 calls and merged their results, got %+v", report)
+		}
+	})
+}
@@ -0,0 +1,276 @@
+package cassette
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/panicpanicpanic/filament/lifx"
+)
+
+// Mode decides whether a Recorder talks to the real API or serves recorded responses
+type Mode int
+
+const (
+	// Auto replays the cassette if its file exists, and records a new one otherwise
+	Auto Mode = iota
+
+	// Replay only serves recorded responses, failing any request that wasn't recorded
+	Replay
+
+	// Record sends every request to the real API and saves it, overwriting the cassette
+	Record
+)
+
+const redacted = "[REDACTED]"
+
+// Cassette is a recorded series of requests and responses
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Interaction is a single recorded request and the response it got
+type Interaction struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+// Request is the recorded part of a request, with the AccessToken scrubbed out
+type Request struct {
+	Method string `json:"method"`
+	Path   string `json:"path"`
+	Query  string `json:"query,omitempty"`
+	Body   string `json:"body,omitempty"`
+}
+
+// Response is a recorded response
+type Response struct {
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header"`
+	Body       string      `json:"body"`
+}
+
+// Recorder is an http.RoundTripper that records interactions to, or replays them from, a cassette file.
+// Requests are matched on method, path, query and body, with JSON bodies compared after normalizing
+// them, and identical requests replay their recorded responses in order.
+type Recorder struct {
+	path string
+	mode Mode
+	next http.RoundTripper
+
+	mu       sync.Mutex
+	cassette Cassette
+	used     []bool
+}
+
+// New returns a Recorder for the cassette file at path. In Record mode requests are sent with next,
+// or http.DefaultTransport if next is nil.
+func New(path string, mode Mode, next http.RoundTripper) (*Recorder, error) {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	if mode == Auto {
+		mode = Record
+		if _, err := os.Stat(path); err == nil {
+			mode = Replay
+		}
+	}
+
+	recorder := &Recorder{path: path, mode: mode, next: next}
+	if mode == Record {
+		return recorder, nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf(err.Error())
+	}
+
+	err = json.Unmarshal(data, &recorder.cassette)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cassette %s: %s", path, err)
+	}
+	recorder.used = make([]bool, len(recorder.cassette.Interactions))
+
+	return recorder, nil
+}
+
+// Mode returns whether the Recorder is recording or replaying
+func (r *Recorder) Mode() Mode {
+	return r.mode
+}
+
+// Instrument makes the client send its requests through the Recorder
+func (r *Recorder) Instrument(client *lifx.Client) {
+	recorded := http.Client{}
+	if client.HTTPClient != nil {
+		recorded = *client.HTTPClient
+	}
+
+	recorded.Transport = r
+	client.HTTPClient = &recorded
+}
+
+// RoundTrip records or replays a single request
+func (r *Recorder) RoundTrip(request *http.Request) (*http.Response, error) {
+	recorded, token, err := recordRequest(request)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.mode == Replay {
+		return r.replay(request, recorded)
+	}
+
+	response, err := r.next.RoundTrip(request)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := ioutil.ReadAll(response.Body)
+	response.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf(err.Error())
+	}
+	response.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	header := http.Header{}
+	for key, values := range response.Header {
+		if key != "Set-Cookie" {
+			header[key] = values
+		}
+	}
+
+	r.mu.Lock()
+	r.cassette.Interactions = append(r.cassette.Interactions, Interaction{
+		Request: recorded,
+		Response: Response{
+			StatusCode: response.StatusCode,
+			Header:     header,
+			Body:       scrub(string(body), token),
+		},
+	})
+	r.mu.Unlock()
+
+	return response, nil
+}
+
+// Stop saves the cassette when recording. It does nothing when replaying.
+func (r *Recorder) Stop() error {
+	if r.mode != Record {
+		return nil
+	}
+
+	r.mu.Lock()
+	data, err := json.MarshalIndent(r.cassette, "", "  ")
+	r.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf(err.Error())
+	}
+
+	err = ioutil.WriteFile(r.path, data, 0644)
+	if err != nil {
+		return fmt.Errorf(err.Error())
+	}
+
+	return nil
+}
+
+// replay returns the first unused matching interaction, or the last match once they've all been used
+func (r *Recorder) replay(request *http.Request, recorded Request) (*http.Response, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	match := -1
+	for i, interaction := range r.cassette.Interactions {
+		if !matches(interaction.Request, recorded) {
+			continue
+		}
+
+		match = i
+		if !r.used[i] {
+			break
+		}
+	}
+
+	if match < 0 {
+		return nil, fmt.Errorf("cassette %s has no recording of %s %s", r.path, recorded.Method, recorded.Path)
+	}
+	r.used[match] = true
+
+	response := r.cassette.Interactions[match].Response
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", response.StatusCode, http.StatusText(response.StatusCode)),
+		StatusCode:    response.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        response.Header,
+		Body:          ioutil.NopCloser(strings.NewReader(response.Body)),
+		ContentLength: int64(len(response.Body)),
+		Request:       request,
+	}, nil
+}
+
+// recordRequest captures the parts of a request used for matching, scrubbing the AccessToken from them
+func recordRequest(request *http.Request) (Request, string, error) {
+	var body []byte
+	var err error
+
+	token := strings.TrimSpace(strings.TrimPrefix(request.Header.Get("Authorization"), "Bearer"))
+
+	if request.Body != nil {
+		body, err = ioutil.ReadAll(request.Body)
+		request.Body.Close()
+		if err != nil {
+			return Request{}, token, fmt.Errorf(err.Error())
+		}
+		request.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+
+	return Request{
+		Method: request.Method,
+		Path:   scrub(request.URL.Path, token),
+		Query:  scrub(request.URL.RawQuery, token),
+		Body:   scrub(normalize(body), token),
+	}, token, nil
+}
+
+func matches(recorded, request Request) bool {
+	return recorded.Method == request.Method &&
+		recorded.Path == request.Path &&
+		recorded.Query == request.Query &&
+		normalize([]byte(recorded.Body)) == request.Body
+}
+
+// normalize re-encodes JSON bodies so key order and whitespace don't affect matching
+func normalize(body []byte) string {
+	var value interface{}
+
+	if len(bytes.TrimSpace(body)) == 0 || string(bytes.TrimSpace(body)) == "null" {
+		return ""
+	}
+
+	if json.Unmarshal(body, &value) != nil {
+		return string(body)
+	}
+
+	normalized, err := json.Marshal(value)
+	if err != nil {
+		return string(body)
+	}
+	return string(normalized)
+}
+
+func scrub(text, token string) string {
+	if token == "" {
+		return text
+	}
+	return strings.Replace(text, token, redacted, -1)
+}
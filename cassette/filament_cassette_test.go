@@ -0,0 +1,79 @@
+package cassette_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/panicpanicpanic/filament"
+	"github.com/panicpanicpanic/filament/cassette"
+	"github.com/panicpanicpanic/filament/lifx"
+)
+
+func TestRecorder(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cassette")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "set_state.json")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusMultiStatus)
+		w.Write([]byte(`{"results": [{"id": "d073d5000000", "status": "ok", "label": "Desk"}]}`))
+	}))
+	client := lifx.Client{AccessToken: "secretToken123", BaseURL: server.URL}
+
+	t.Run("when recording a cassette", func(t *testing.T) {
+		recorder, err := cassette.New(path, cassette.Auto, nil)
+		if err != nil || recorder.Mode() != cassette.Record {
+			t.Fatalf("it should have started recording, got %v", err)
+		}
+		recorder.Instrument(&client)
+
+		_, err = filament.SetState(&client, "label:Desk", json.RawMessage(`{"power": "on", "brightness": 0.5}`))
+		if err != nil {
+			t.Fatalf("it should have reached the server, got %s", err)
+		}
+
+		err = recorder.Stop()
+		if err != nil {
+			t.Fatalf("it should have saved the cassette, got %s", err)
+		}
+
+		data, _ := ioutil.ReadFile(path)
+		if strings.Contains(string(data), "secretToken123") {
+			t.Errorf("it should have scrubbed the token from the cassette, got %s", data)
+		}
+	})
+
+	server.Close()
+
+	t.Run("when replaying a cassette", func(t *testing.T) {
+		recorder, err := cassette.New(path, cassette.Auto, nil)
+		if err != nil || recorder.Mode() != cassette.Replay {
+			t.Fatalf("it should have started replaying, got %v", err)
+		}
+		recorder.Instrument(&client)
+
+		// The same payload with its keys in a different order should still match. Raw JSON is used
+		// since json.Marshal would sort the keys of a map itself.
+		response, err := filament.SetState(&client, "label:Desk", json.RawMessage(`{"brightness":0.5,"power":"on"}`))
+		if err != nil {
+			t.Fatalf("it should have replayed the recorded response, got %s", err)
+		}
+		if len(response.Results) != 1 || response.Results[0].Label != "Desk" {
+			t.Errorf("it should have decoded the recorded results, got %+v", response)
+		}
+
+		_, err = filament.TogglePower(&client, "label:Desk")
+		if err == nil {
+			t.Errorf("it should have failed a request that wasn't recorded")
+		}
+	})
+}
@@ -0,0 +1,104 @@
+package filament
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/panicpanicpanic/filament/lifx"
+)
+
+// AccountInfo is what LIFX reveals about the account an AccessToken belongs to when it is checked
+type AccountInfo struct {
+	// RateLimit and RateLimitRemaining come from the LIFX rate limit headers, and are 0 if they were missing
+	RateLimit          int
+	RateLimitRemaining int
+
+	// Scopes lists the OAuth scopes granted to the token, when LIFX reports them. Personal access
+	// tokens have full access and usually report none.
+	Scopes []string
+}
+
+// NewClient returns a copy of template after checking with LIFX that its AccessToken works, using the
+// same call as HealthCheck. Invalid tokens return an error matching lifx.ErrUnauthorized or
+// lifx.ErrForbidden.
+func NewClient(ctx context.Context, template lifx.Client) (*lifx.Client, AccountInfo, error) {
+	var info AccountInfo
+
+	client := template
+	if client.AccessToken == "" {
+		return nil, info, fmt.Errorf("unable to create client: %w", lifx.ErrUnauthorized)
+	}
+
+	header, err := checkToken(ctx, &client)
+	if err != nil {
+		if lifx.IsAuthError(err) {
+			return nil, info, fmt.Errorf("LIFX rejected the access token: %w", err)
+		}
+		return nil, info, fmt.Errorf("unable to validate the access token: %w", err)
+	}
+
+	info.RateLimit, _ = strconv.Atoi(header.Get("X-RateLimit-Limit"))
+	info.RateLimitRemaining, _ = strconv.Atoi(header.Get("X-RateLimit-Remaining"))
+	info.Scopes = strings.FieldsFunc(header.Get("X-OAuth-Scopes"), isScopeSeparator)
+
+	return &client, info, nil
+}
+
+// HealthCheck makes the cheapest possible call to LIFX, returning an error if it or the client's
+// AccessToken isn't working
+func HealthCheck(ctx context.Context, client *lifx.Client) error {
+	_, err := checkToken(ctx, client)
+	return err
+}
+
+// checkToken validates a color, which needs a working AccessToken but doesn't touch any lights or
+// download the account's inventory, and returns the response headers
+func checkToken(ctx context.Context, client *lifx.Client) (http.Header, error) {
+	recorder := &headerRecorder{}
+	check := *client
+	check.Context = ctx
+	check.Observers = append(append([]lifx.Observer(nil), client.Observers...), recorder)
+
+	_, err := ValidateColor(&check, "white")
+	return recorder.header, err
+}
+
+// HealthHandler returns an http.Handler for readiness probes. It responds 200 when HealthCheck
+// passes, and 503 otherwise, saying whether the AccessToken was the problem.
+func HealthHandler(client *lifx.Client) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := HealthCheck(r.Context(), client)
+
+		switch {
+		case err == nil:
+			w.WriteHeader(http.StatusOK)
+			fmt.Fprintln(w, "ok")
+		case lifx.IsAuthError(err):
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprintln(w, "LIFX rejected the access token")
+		default:
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprintln(w, "LIFX is unreachable")
+		}
+	})
+}
+
+// headerRecorder is a lifx.Observer that keeps the response headers of the last call
+type headerRecorder struct {
+	header http.Header
+}
+
+func (h *headerRecorder) Begin(ctx context.Context, call *lifx.Call) context.Context {
+	return ctx
+}
+
+func (h *headerRecorder) End(ctx context.Context, call *lifx.Call) {
+	h.header = call.ResponseHeader
+}
+
+func isScopeSeparator(r rune) bool {
+	return r == ',' || r == ' '
+}
@@ -0,0 +1,58 @@
+package filament_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/panicpanicpanic/filament"
+	"github.com/panicpanicpanic/filament/lifx"
+)
+
+func TestNewClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer goodToken" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error": "Invalid token"}`))
+			return
+		}
+
+		if r.URL.Path != "/color" {
+			t.Errorf("it should only have validated a color, got %s", r.URL.Path)
+		}
+
+		w.Header().Set("X-RateLimit-Limit", "120")
+		w.Header().Set("X-OAuth-Scopes", "lights:read, lights:write")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"hue": 0, "saturation": 0, "kelvin": 3500}`))
+	}))
+	defer server.Close()
+
+	t.Run("when the access token is valid", func(t *testing.T) {
+		client, info, err := filament.NewClient(context.Background(), lifx.Client{AccessToken: "goodToken", BaseURL: server.URL})
+		if err != nil || client == nil {
+			t.Fatalf("it should have returned a client, got %v", err)
+		}
+		if info.RateLimit != 120 || len(info.Scopes) != 2 || info.Scopes[1] != "lights:write" {
+			t.Errorf("it should have described the account, got %+v", info)
+		}
+	})
+
+	t.Run("when the access token is rejected", func(t *testing.T) {
+		_, _, err := filament.NewClient(context.Background(), lifx.Client{AccessToken: "badToken", BaseURL: server.URL})
+		if !errors.Is(err, lifx.ErrUnauthorized) {
+			t.Errorf("it should have classified the error as unauthorized, got %v", err)
+		}
+	})
+
+	t.Run("when the health check fails", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		filament.HealthHandler(&lifx.Client{AccessToken: "badToken", BaseURL: server.URL}).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/ready", nil))
+
+		if recorder.Code != http.StatusServiceUnavailable {
+			t.Errorf("it should have returned a 503 HTTP status, got %d", recorder.Code)
+		}
+	})
+}
@@ -0,0 +1,55 @@
+// Command filament-proxy serves a LIFX compatible REST API guarded by its own API keys, so internal
+// services can control lights without ever seeing the LIFX access token.
+//
+// Usage:
+//
+//	LIFX_ACCESS_TOKEN=... filament-proxy -keys keys.json -listen :8080
+//
+// The keys file is a JSON array of {"name": "...", "key": "...", "selectors": ["group:Office"]}.
+// Callers authenticate with "Authorization: Bearer <key>" exactly as they would with LIFX.
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/panicpanicpanic/filament/lifx"
+	"github.com/panicpanicpanic/filament/proxy"
+)
+
+func main() {
+	listen := flag.String("listen", ":8080", "address to serve the proxy on")
+	keysPath := flag.String("keys", "keys.json", "path to the JSON file of proxy API keys")
+	rate := flag.Int("rate", lifx.DefaultRateLimit, "LIFX requests allowed per minute, shared by every caller")
+	flag.Parse()
+
+	token := os.Getenv("LIFX_ACCESS_TOKEN")
+	if token == "" {
+		log.Fatal("LIFX_ACCESS_TOKEN must be set")
+	}
+
+	keys, err := proxy.LoadKeys(*keysPath)
+	if err != nil {
+		log.Fatalf("unable to load keys: %s", err)
+	}
+
+	client := lifx.Client{
+		AccessToken: token,
+		Limiter:     lifx.NewRateLimiter(*rate, lifx.DefaultRatePeriod),
+	}
+
+	log.Printf("filament-proxy listening on %s with %d keys", *listen, len(keys))
+	server := &http.Server{
+		Addr:    *listen,
+		Handler: proxy.NewServer(&client, keys),
+
+		// Requests can wait on the shared rate limiter, so writes get longer than reads
+		ReadTimeout:  10 * time.Second,
+		WriteTimeout: 2 * time.Minute,
+		IdleTimeout:  2 * time.Minute,
+	}
+	log.Fatal(server.ListenAndServe())
+}
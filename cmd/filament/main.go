@@ -0,0 +1,249 @@
+// Command filament controls LIFX lights from the command line.
+//
+// Usage:
+//
+//	filament <command> [flags]
+//
+// Settings come from the profile in ~/.config/filament/config.yaml picked by FILAMENT_PROFILE, and
+// can be overridden with environment variables such as LIFX_ACCESS_TOKEN.
+//
+// Shell completion is installed by loading the output of `filament completion bash|zsh|fish`.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/panicpanicpanic/filament"
+	"github.com/panicpanicpanic/filament/completion"
+	"github.com/panicpanicpanic/filament/config"
+	"github.com/panicpanicpanic/filament/lifx"
+	"github.com/panicpanicpanic/filament/tui"
+)
+
+const usage = `Usage: filament <command> [flags]
+
+Commands:
+  tui [-selector s]      browse and control lights interactively
+  toggle [selector]      toggle the power of lights
+  scene <name>           activate a scene by name
+  refresh                refresh the inventory used by shell completion
+  completion <shell>     print the completion script for bash, zsh or fish
+`
+
+// commands are the names offered when completing the first word
+var commands = []string{"tui", "toggle", "scene", "refresh", "completion"}
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	var err error
+	args := os.Args[2:]
+	switch os.Args[1] {
+	case "tui":
+		err = runTUI(args)
+	case "toggle":
+		err = runToggle(args)
+	case "scene":
+		err = runScene(args)
+	case "refresh":
+		err = runRefresh()
+	case "completion":
+		err = runCompletion(args)
+	case "__complete":
+		runComplete(args)
+	case "help", "-h", "--help":
+		fmt.Print(usage)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", os.Args[1], usage)
+		os.Exit(2)
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "filament:", err)
+		os.Exit(1)
+	}
+}
+
+func runTUI(args []string) error {
+	flags := flag.NewFlagSet("tui", flag.ExitOnError)
+	selector := flags.String("selector", "", "lights to show, defaulting to the profile's selector")
+	interval := flags.Duration("interval", 10*time.Second, "how often to refresh the lights")
+	flags.Parse(args)
+
+	profile, client, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	return tui.Run(context.Background(), client, profile.Expand(*selector), *interval)
+}
+
+func runToggle(args []string) error {
+	if len(args) > 1 {
+		return fmt.Errorf("usage: filament toggle [selector]")
+	}
+
+	profile, client, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	selector := ""
+	if len(args) == 1 {
+		selector = args[0]
+	}
+
+	_, err = filament.TogglePower(client, profile.Expand(selector))
+	return err
+}
+
+func runScene(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: filament scene <name>")
+	}
+
+	_, client, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	scenes, err := filament.GetScenes(client)
+	if err != nil {
+		return err
+	}
+
+	for _, scene := range scenes {
+		if strings.EqualFold(scene.Name, args[0]) {
+			_, err = filament.ActivateScene(client, scene.UUID, nil)
+			return err
+		}
+	}
+
+	return fmt.Errorf("no scene named %q", args[0])
+}
+
+func runRefresh() error {
+	_, client, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	inventory, err := completion.Fetch(client)
+	if err != nil {
+		return err
+	}
+
+	cache := completion.Cache{Path: completion.DefaultPath()}
+	return cache.Save(inventory)
+}
+
+func runCompletion(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: filament completion <%s>", strings.Join(completion.Shells, "|"))
+	}
+
+	script, err := completion.Script(args[0], "filament")
+	if err != nil {
+		return err
+	}
+
+	fmt.Print(script)
+	return nil
+}
+
+// runComplete prints the candidates for the last of words, one per line. It is called by the
+// completion scripts, so it stays quiet about errors and never waits long on the LIFX API.
+func runComplete(args []string) {
+	if len(args) < 2 {
+		return
+	}
+	shell, words := args[0], args[1:]
+
+	cache := completion.Cache{Path: completion.DefaultPath()}
+	profile, client, err := newClient()
+	if err == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		defer cancel()
+		client.Context = ctx
+	}
+	inventory, _ := cache.Get(client)
+
+	for _, candidate := range candidates(inventory, profile.Aliases, words) {
+		fmt.Println(completion.Quote(shell, candidate))
+	}
+}
+
+// candidates decides what the last of words is from the words before it
+func candidates(inventory completion.Inventory, aliases map[string]string, words []string) []string {
+	current := words[len(words)-1]
+	previous := words[:len(words)-1]
+
+	if len(previous) == 0 {
+		return prefixed(commands, current)
+	}
+
+	switch previous[0] {
+	case "toggle":
+		if len(previous) == 1 {
+			return selectors(inventory, aliases, current)
+		}
+	case "tui":
+		if previous[len(previous)-1] == "-selector" {
+			return selectors(inventory, aliases, current)
+		}
+		return prefixed([]string{"-selector", "-interval"}, current)
+	case "scene":
+		if len(previous) == 1 {
+			return completion.Scenes(inventory, current)
+		}
+	case "completion":
+		if len(previous) == 1 {
+			return prefixed(completion.Shells, current)
+		}
+	}
+
+	return nil
+}
+
+// selectors offers the profile's aliases alongside the selectors in inventory
+func selectors(inventory completion.Inventory, aliases map[string]string, current string) []string {
+	var names []string
+	for alias := range aliases {
+		names = append(names, alias)
+	}
+	sort.Strings(names)
+
+	return append(prefixed(names, current), completion.Selectors(inventory, current)...)
+}
+
+func prefixed(names []string, prefix string) []string {
+	var matches []string
+
+	for _, name := range names {
+		if strings.HasPrefix(name, prefix) {
+			matches = append(matches, name)
+		}
+	}
+
+	return matches
+}
+
+// newClient builds a client from the active config profile
+func newClient() (config.Profile, *lifx.Client, error) {
+	profile, err := config.Default()
+	if err != nil {
+		return profile, nil, err
+	}
+
+	client, err := profile.Client()
+	return profile, client, err
+}
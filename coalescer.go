@@ -0,0 +1,136 @@
+package filament
+
+import (
+	"sync"
+	"time"
+
+	"github.com/panicpanicpanic/filament/lifx"
+)
+
+// defaultCoalesceWindow is how long a Coalescer gathers changes when Window isn't set. It is the
+// spacing the LIFX rate limit allows, so one selector changed nonstop stays within it.
+const defaultCoalesceWindow = lifx.DefaultRatePeriod / lifx.DefaultRateLimit
+
+// Coalescer sits in front of SetState for callers that fire many changes at the same lights, such as
+// a slider. Changes to a selector within Window of the first are merged, later fields winning, and sent
+// as one SetState. Every caller whose change was merged gets that call's lifx.Response.
+//
+// Calls are sent through the Client's Limiter. If the Client has no Limiter, the Coalescer creates
+// one with the LIFX defaults, so many selectors changing at once stay within the rate limit too.
+type Coalescer struct {
+	Client *lifx.Client
+	Window time.Duration
+
+	mutex   sync.Mutex
+	pending map[string]*flight
+	last    map[string]*flight
+	once    sync.Once
+	limiter *lifx.RateLimiter
+}
+
+// flight is one SetState call being gathered or sent for a selector
+type flight struct {
+	selector string
+	state    map[string]interface{}
+	timer    *time.Timer
+	previous *flight
+	done     chan struct{}
+	response lifx.Response
+	err      error
+}
+
+// NewCoalescer returns a Coalescer sending with client, gathering changes for window
+func NewCoalescer(client *lifx.Client, window time.Duration) *Coalescer {
+	return &Coalescer{Client: client, Window: window}
+}
+
+// SetState merges state into the change waiting to be sent to the lights within selector, and waits
+// for it to be sent
+func (c *Coalescer) SetState(selector string, state map[string]interface{}) (lifx.Response, error) {
+	if selector == "" {
+		selector = "all"
+	}
+
+	c.mutex.Lock()
+	if c.pending == nil {
+		c.pending = map[string]*flight{}
+		c.last = map[string]*flight{}
+	}
+
+	f, ok := c.pending[selector]
+	if ok {
+		f.state = merge(f.state, state)
+	} else {
+		window := c.Window
+		if window <= 0 {
+			window = defaultCoalesceWindow
+		}
+
+		// The window is counted from the first change rather than restarted by every change, so a
+		// steady stream of changes still gets sent
+		f = &flight{selector: selector, state: merge(state, nil), previous: c.last[selector], done: make(chan struct{})}
+		f.timer = time.AfterFunc(window, func() {
+			c.send(f)
+		})
+		c.pending[selector] = f
+		c.last[selector] = f
+	}
+	c.mutex.Unlock()
+
+	<-f.done
+	return f.response, f.err
+}
+
+// Flush sends every waiting change straight away
+func (c *Coalescer) Flush() {
+	c.mutex.Lock()
+	var flights []*flight
+	for _, f := range c.pending {
+		if f.timer.Stop() {
+			flights = append(flights, f)
+		}
+	}
+	c.mutex.Unlock()
+
+	var wg sync.WaitGroup
+	for _, f := range flights {
+		wg.Add(1)
+		go func(f *flight) {
+			defer wg.Done()
+			c.send(f)
+		}(f)
+	}
+	wg.Wait()
+}
+
+func (c *Coalescer) send(f *flight) {
+	c.mutex.Lock()
+	if c.pending[f.selector] == f {
+		delete(c.pending, f.selector)
+	}
+	c.mutex.Unlock()
+
+	// Calls to the same selector are sent in order, so an older state never lands after a newer one
+	if f.previous != nil {
+		<-f.previous.done
+
+		// Let the previous call be collected rather than chaining every call ever sent
+		f.previous = nil
+	}
+
+	client := *c.Client
+	if client.Limiter == nil {
+		c.once.Do(func() {
+			c.limiter = lifx.NewRateLimiter(lifx.DefaultRateLimit, lifx.DefaultRatePeriod)
+		})
+		client.Limiter = c.limiter
+	}
+	f.response, f.err = SetState(&client, f.selector, f.state)
+	close(f.done)
+
+	c.mutex.Lock()
+	if c.last[f.selector] == f {
+		delete(c.last, f.selector)
+	}
+	c.mutex.Unlock()
+}
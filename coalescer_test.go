@@ -0,0 +1,100 @@
+package filament_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/panicpanicpanic/filament"
+	"github.com/panicpanicpanic/filament/lifx"
+)
+
+func TestCoalescer(t *testing.T) {
+	var mutex sync.Mutex
+	var requests []string
+	var payloads []map[string]interface{}
+	var times []time.Time
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&payload)
+
+		mutex.Lock()
+		requests = append(requests, r.URL.Path)
+		payloads = append(payloads, payload)
+		times = append(times, time.Now())
+		mutex.Unlock()
+
+		w.WriteHeader(http.StatusMultiStatus)
+		w.Write([]byte(`{"results": [{"id": "d1", "status": "ok", "label": "Desk"}]}`))
+	}))
+	defer server.Close()
+
+	client := &lifx.Client{AccessToken: "someRandomToken", BaseURL: server.URL}
+
+	t.Run("when a selector is set many times within the window", func(t *testing.T) {
+		coalescer := filament.NewCoalescer(client, 50*time.Millisecond)
+
+		var wg sync.WaitGroup
+		responses := make([]lifx.Response, 10)
+		for i := range responses {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				responses[i], _ = coalescer.SetState("label:Desk", map[string]interface{}{"brightness": float64(i) / 10})
+			}(i)
+			time.Sleep(time.Millisecond)
+		}
+		coalescer.SetState("label:Desk", map[string]interface{}{"brightness": 1.0, "power": "on"})
+		wg.Wait()
+
+		mutex.Lock()
+		defer mutex.Unlock()
+		if len(requests) != 1 || requests[0] != "/lights/label:Desk/state" {
+			t.Fatalf("it should have sent a single request, got %v", requests)
+		}
+		if payloads[0]["brightness"] != 1.0 || payloads[0]["power"] != "on" {
+			t.Errorf("it should have sent the latest state, got %v", payloads[0])
+		}
+		for i, response := range responses {
+			if len(response.Results) != 1 || response.Results[0].Label != "Desk" {
+				t.Errorf("it should have given caller %d the response, got %+v", i, response)
+			}
+		}
+	})
+
+	t.Run("when different selectors are set", func(t *testing.T) {
+		mutex.Lock()
+		requests, payloads, times = nil, nil, nil
+		mutex.Unlock()
+
+		coalescer := filament.NewCoalescer(client, time.Hour)
+
+		var wg sync.WaitGroup
+		for _, selector := range []string{"label:Desk", "label:Porch"} {
+			wg.Add(1)
+			go func(selector string) {
+				defer wg.Done()
+				coalescer.SetState(selector, map[string]interface{}{"power": "off"})
+			}(selector)
+		}
+
+		time.Sleep(20 * time.Millisecond)
+		coalescer.Flush()
+		wg.Wait()
+
+		mutex.Lock()
+		defer mutex.Unlock()
+		if len(requests) != 2 {
+			t.Fatalf("it should have sent one request per selector when flushed, got %v", requests)
+		}
+
+		interval := lifx.DefaultRatePeriod / lifx.DefaultRateLimit
+		if gap := times[1].Sub(times[0]); gap < interval-50*time.Millisecond {
+			t.Errorf("it should have spaced the requests out to the rate limit, got %s apart", gap)
+		}
+	})
+}
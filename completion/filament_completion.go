@@ -0,0 +1,207 @@
+package completion
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/panicpanicpanic/filament"
+	"github.com/panicpanicpanic/filament/lifx"
+)
+
+// DefaultTTL is how long a cached Inventory is used before it is fetched again
+const DefaultTTL = time.Hour
+
+// Inventory is the names completion offers, saved locally so pressing tab doesn't call the LIFX API
+type Inventory struct {
+	Labels    []string  `json:"labels"`
+	Groups    []string  `json:"groups"`
+	Locations []string  `json:"locations"`
+	Scenes    []string  `json:"scenes"`
+	Updated   time.Time `json:"updated"`
+}
+
+// Fetch builds an Inventory from GetLights and GetScenes
+func Fetch(client *lifx.Client) (Inventory, error) {
+	devices, err := filament.GetLights(client, "all")
+	if err != nil {
+		return Inventory{}, err
+	}
+
+	scenes, err := filament.GetScenes(client)
+	if err != nil {
+		return Inventory{}, err
+	}
+
+	labels := map[string]bool{}
+	groups := map[string]bool{}
+	locations := map[string]bool{}
+	for _, d := range devices {
+		labels[d.Label] = true
+		groups[d.Group.Name] = true
+		locations[d.Location.Name] = true
+	}
+
+	names := map[string]bool{}
+	for _, scene := range scenes {
+		names[scene.Name] = true
+	}
+
+	return Inventory{
+		Labels:    sorted(labels),
+		Groups:    sorted(groups),
+		Locations: sorted(locations),
+		Scenes:    sorted(names),
+		Updated:   time.Now(),
+	}, nil
+}
+
+// Cache keeps an Inventory in a file, fetching a new one once it is older than TTL
+type Cache struct {
+	Path string
+	TTL  time.Duration
+}
+
+// DefaultPath is where the Inventory is cached unless told otherwise
+func DefaultPath() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "filament", "inventory.json")
+}
+
+// Load reads the cached Inventory, returning an empty one if nothing has been cached yet
+func (c *Cache) Load() (Inventory, error) {
+	var inventory Inventory
+
+	body, err := ioutil.ReadFile(c.Path)
+	if os.IsNotExist(err) {
+		return inventory, nil
+	}
+	if err != nil {
+		return inventory, fmt.Errorf(err.Error())
+	}
+
+	err = json.Unmarshal(body, &inventory)
+	if err != nil {
+		return inventory, fmt.Errorf(err.Error())
+	}
+
+	return inventory, nil
+}
+
+// Save writes inventory to the cache
+func (c *Cache) Save(inventory Inventory) error {
+	body, err := json.Marshal(inventory)
+	if err != nil {
+		return fmt.Errorf(err.Error())
+	}
+
+	err = os.MkdirAll(filepath.Dir(c.Path), 0700)
+	if err != nil {
+		return fmt.Errorf(err.Error())
+	}
+
+	// Write to a temporary file first so a completion running at the same time never reads half a file
+	temp := c.Path + ".tmp"
+	err = ioutil.WriteFile(temp, body, 0600)
+	if err != nil {
+		return fmt.Errorf(err.Error())
+	}
+
+	return os.Rename(temp, c.Path)
+}
+
+// Stale reports whether inventory is older than the cache's TTL
+func (c *Cache) Stale(inventory Inventory) bool {
+	ttl := c.TTL
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	return time.Since(inventory.Updated) > ttl
+}
+
+// Get returns the cached Inventory, fetching and saving a new one with client when it is stale.
+// If fetching fails the stale Inventory is returned along with the error, so completion can carry on.
+func (c *Cache) Get(client *lifx.Client) (Inventory, error) {
+	inventory, err := c.Load()
+	if err != nil || !c.Stale(inventory) || client == nil {
+		return inventory, err
+	}
+
+	fresh, err := Fetch(client)
+	if err != nil {
+		return inventory, err
+	}
+
+	return fresh, c.Save(fresh)
+}
+
+// Selectors returns the selectors in inventory that start with prefix. Until prefix names a kind
+// of selector, the kinds themselves are offered.
+func Selectors(inventory Inventory, prefix string) []string {
+	kinds := []struct {
+		prefix string
+		names  []string
+	}{
+		{"label:", inventory.Labels},
+		{"group:", inventory.Groups},
+		{"location:", inventory.Locations},
+	}
+
+	var matches []string
+	for _, kind := range kinds {
+		if strings.HasPrefix(prefix, kind.prefix) {
+			return match(kind.names, kind.prefix, strings.TrimPrefix(prefix, kind.prefix))
+		}
+		if strings.HasPrefix(kind.prefix, prefix) {
+			matches = append(matches, kind.prefix)
+		}
+	}
+
+	for _, fixed := range []string{"all", "id:", "group_id:", "location_id:", "scene_id:"} {
+		if strings.HasPrefix(fixed, prefix) {
+			matches = append(matches, fixed)
+		}
+	}
+
+	sort.Strings(matches)
+	return matches
+}
+
+// Scenes returns the scene names in inventory that start with prefix
+func Scenes(inventory Inventory, prefix string) []string {
+	return match(inventory.Scenes, "", prefix)
+}
+
+// match returns each name starting with partial, ignoring case, with kind in front of it
+func match(names []string, kind, partial string) []string {
+	var matches []string
+
+	for _, name := range names {
+		if strings.HasPrefix(strings.ToLower(name), strings.ToLower(partial)) {
+			matches = append(matches, kind+name)
+		}
+	}
+
+	return matches
+}
+
+func sorted(set map[string]bool) []string {
+	var names []string
+
+	for name := range set {
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+	return names
+}
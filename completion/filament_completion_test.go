@@ -0,0 +1,138 @@
+package completion_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/panicpanicpanic/filament/completion"
+	"github.com/panicpanicpanic/filament/lifx"
+)
+
+func TestSelectors(t *testing.T) {
+	inventory := completion.Inventory{
+		Labels:    []string{"Desk Lamp", "Door"},
+		Groups:    []string{"Study"},
+		Locations: []string{"Home"},
+	}
+
+	t.Run("when no kind has been typed", func(t *testing.T) {
+		matches := completion.Selectors(inventory, "l")
+		expected := []string{"label:", "location:", "location_id:"}
+		if !reflect.DeepEqual(matches, expected) {
+			t.Errorf("it should have offered %v, got %v", expected, matches)
+		}
+	})
+
+	t.Run("when a label is partly typed", func(t *testing.T) {
+		matches := completion.Selectors(inventory, "label:de")
+		if !reflect.DeepEqual(matches, []string{"label:Desk Lamp"}) {
+			t.Errorf("it should have matched the label ignoring case, got %v", matches)
+		}
+	})
+
+	t.Run("when a group is partly typed", func(t *testing.T) {
+		matches := completion.Selectors(inventory, "group:")
+		if !reflect.DeepEqual(matches, []string{"group:Study"}) {
+			t.Errorf("it should have offered every group, got %v", matches)
+		}
+	})
+}
+
+func TestCache(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if strings.HasSuffix(r.URL.Path, "/scenes") {
+			w.Write([]byte(`[{"uuid": "1", "name": "Evening"}]`))
+			return
+		}
+		w.Write([]byte(`[{"id": "1", "label": "Lamp", "group": {"name": "Study"}, "location": {"name": "Home"}}]`))
+	}))
+	defer server.Close()
+
+	client := &lifx.Client{AccessToken: "someRandomToken", BaseURL: server.URL}
+	cache := completion.Cache{Path: filepath.Join(t.TempDir(), "filament", "inventory.json")}
+
+	t.Run("when nothing has been cached", func(t *testing.T) {
+		inventory, err := cache.Get(client)
+		if err != nil {
+			t.Fatalf("it should have fetched the inventory, got %s", err)
+		}
+		if !reflect.DeepEqual(inventory.Labels, []string{"Lamp"}) || !reflect.DeepEqual(inventory.Scenes, []string{"Evening"}) {
+			t.Errorf("it should have collected labels and scenes, got %+v", inventory)
+		}
+		if requests != 2 {
+			t.Errorf("it should have made 2 requests, got %d", requests)
+		}
+	})
+
+	t.Run("when the cache is fresh", func(t *testing.T) {
+		inventory, err := cache.Get(client)
+		if err != nil || len(inventory.Groups) != 1 {
+			t.Errorf("it should have loaded the cached inventory, got %+v %v", inventory, err)
+		}
+		if requests != 2 {
+			t.Errorf("it should not have called the API again, got %d requests", requests)
+		}
+	})
+
+	t.Run("when the cache is stale", func(t *testing.T) {
+		err := cache.Save(completion.Inventory{Updated: time.Now().Add(-2 * completion.DefaultTTL)})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = cache.Get(client)
+		if err != nil || requests != 4 {
+			t.Errorf("it should have fetched the inventory again, got %d requests", requests)
+		}
+	})
+}
+
+func TestScript(t *testing.T) {
+	for _, shell := range completion.Shells {
+		script, err := completion.Script(shell, "filament")
+		if err != nil || !strings.Contains(script, "filament __complete "+shell) {
+			t.Errorf("it should have written a %s script calling __complete, got %q", shell, script)
+		}
+	}
+
+	if _, err := completion.Script("tcsh", "filament"); err == nil {
+		t.Errorf("it should have rejected an unsupported shell")
+	}
+}
+
+func TestBashScript(t *testing.T) {
+	bash, err := exec.LookPath("bash")
+	if err != nil {
+		t.Skip("bash is not installed")
+	}
+
+	script, _ := completion.Script("bash", "filament")
+
+	// filament is stubbed with a function that echoes back the words it was asked to complete
+	complete := func(line string) string {
+		stub := `filament() { shift 2; printf '%s,' "$@"; echo; echo "label:Desk"; }` + "\n" + script + `
+COMP_LINE="$1"; COMP_POINT=${#COMP_LINE}
+_filament_complete
+printf '%s' "${COMPREPLY[*]}"`
+
+		output, err := exec.Command(bash, "-c", stub, "bash", line).Output()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(output)
+	}
+
+	t.Run("when a selector with a colon is partly typed", func(t *testing.T) {
+		if got := complete("filament on label:De"); got != "on,label:De, Desk" {
+			t.Errorf("it should have passed the selector whole and trimmed the candidate after the colon, got %q", got)
+		}
+	})
+}
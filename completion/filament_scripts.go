@@ -0,0 +1,93 @@
+package completion
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Shells are the shells Script can write completion for
+var Shells = []string{"bash", "zsh", "fish"}
+
+// Script returns the completion script for shell. Each script asks the named command for its
+// candidates by running `<command> __complete <shell> <words...>`, so the logic lives in one place.
+func Script(shell, command string) (string, error) {
+	var script string
+
+	switch shell {
+	case "bash":
+		script = bashScript
+	case "zsh":
+		script = zshScript
+	case "fish":
+		script = fishScript
+	default:
+		return "", fmt.Errorf("unsupported shell %q, expected one of %s", shell, strings.Join(Shells, ", "))
+	}
+
+	return strings.Replace(script, "{{command}}", command, -1), nil
+}
+
+// Quote prepares a candidate for shell. Bash treats spaces in candidates as word breaks, so they
+// are escaped; zsh and fish quote candidates themselves.
+func Quote(shell, candidate string) string {
+	if shell != "bash" {
+		return candidate
+	}
+
+	return strings.NewReplacer(" ", `\ `, "'", `\'`, `"`, `\"`).Replace(candidate)
+}
+
+const bashScript = `# bash completion for {{command}}
+_{{command}}_complete() {
+	local cur words cword
+	if declare -F _get_comp_words_by_ref >/dev/null; then
+		_get_comp_words_by_ref -n : cur words cword
+	else
+		# COMP_WORDS splits "label:Desk" at the colon, so split the line up to the cursor instead
+		local line="${COMP_LINE:0:COMP_POINT}"
+		read -ra words <<< "$line"
+		if [[ -z "$line" || "$line" == *" " ]]; then
+			cword=${#words[@]}
+			words+=("")
+		else
+			cword=$((${#words[@]} - 1))
+		fi
+		cur="${words[cword]}"
+	fi
+
+	local IFS=$'\n'
+	COMPREPLY=($({{command}} __complete bash "${words[@]:1:cword-1}" "$cur" 2>/dev/null))
+
+	# Bash only replaces the text after the last colon, so drop everything up to it from the candidates
+	if declare -F __ltrim_colon_completions >/dev/null; then
+		__ltrim_colon_completions "$cur"
+	elif [[ "$cur" == *:* && "$COMP_WORDBREAKS" == *:* ]]; then
+		local prefix="${cur%"${cur##*:}"}" i
+		for i in "${!COMPREPLY[@]}"; do
+			COMPREPLY[i]="${COMPREPLY[i]#"$prefix"}"
+		done
+	fi
+
+	# Leave the cursor after a selector prefix such as "label:" so the name can be typed straight away
+	if [[ ${#COMPREPLY[@]} -eq 1 && "${COMPREPLY[0]}" == *: ]]; then
+		compopt -o nospace 2>/dev/null
+	fi
+}
+complete -F _{{command}}_complete {{command}}
+`
+
+const zshScript = `#compdef {{command}}
+_{{command}}() {
+	local -a candidates
+	candidates=(${(f)"$({{command}} __complete zsh "${(@)words[2,CURRENT]}" 2>/dev/null)"})
+
+	# Selector prefixes such as "label:" get no trailing space, so the name can be typed straight away
+	compadd -S '' -- ${(M)candidates:#*:}
+	compadd -- ${candidates:#*:}
+}
+compdef _{{command}} {{command}}
+`
+
+const fishScript = `# fish completion for {{command}}
+complete -c {{command}} -f -a '({{command}} __complete fish (commandline -opc)[2..-1] (commandline -ct) 2>/dev/null)'
+`
@@ -0,0 +1,227 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/panicpanicpanic/filament/lifx"
+	"gopkg.in/yaml.v2"
+)
+
+// DefaultProfile is the profile used when neither the config file nor FILAMENT_PROFILE names one
+const DefaultProfile = "default"
+
+// Environment variables that override the config file
+const (
+	EnvConfig     = "FILAMENT_CONFIG"
+	EnvProfile    = "FILAMENT_PROFILE"
+	EnvToken      = "LIFX_ACCESS_TOKEN"
+	EnvBaseURL    = "FILAMENT_BASE_URL"
+	EnvTimeout    = "FILAMENT_TIMEOUT"
+	EnvRetries    = "FILAMENT_RETRIES"
+	EnvRateLimit  = "FILAMENT_RATE_LIMIT"
+	EnvRatePeriod = "FILAMENT_RATE_PERIOD"
+	EnvSelector   = "FILAMENT_SELECTOR"
+)
+
+// Config is the contents of a filament config file, e.g.
+//
+//	profile: home
+//	aliases:
+//	  desk: label:Desk Lamp
+//	profiles:
+//	  home:
+//	    token: c87c...
+//	    timeout: 10s
+//	    retries: 2
+//	    selector: group:Study
+type Config struct {
+	// Profile is the profile used when none is asked for
+	Profile  string             `yaml:"profile"`
+	Profiles map[string]Profile `yaml:"profiles"`
+
+	// Aliases are shared by every profile. A profile's own aliases win over these.
+	Aliases map[string]string `yaml:"aliases"`
+}
+
+// Profile is the settings for one LIFX account or setup
+type Profile struct {
+	Name       string            `yaml:"-"`
+	Token      string            `yaml:"token"`
+	BaseURL    string            `yaml:"base_url"`
+	Timeout    time.Duration     `yaml:"timeout"`
+	Retries    int               `yaml:"retries"`
+	RateLimit  int               `yaml:"rate_limit"`
+	RatePeriod time.Duration     `yaml:"rate_period"`
+	Selector   string            `yaml:"selector"`
+	Aliases    map[string]string `yaml:"aliases"`
+}
+
+// DefaultPath is where the config file lives unless FILAMENT_CONFIG says otherwise:
+// $XDG_CONFIG_HOME/filament/config.yaml, falling back to ~/.config/filament/config.yaml
+func DefaultPath() string {
+	if path := os.Getenv(EnvConfig); path != "" {
+		return path
+	}
+
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, _ := os.UserHomeDir()
+		dir = filepath.Join(home, ".config")
+	}
+
+	return filepath.Join(dir, "filament", "config.yaml")
+}
+
+// Load reads the config file at path. A missing file is not an error, so programs can be configured
+// with environment variables alone.
+func Load(path string) (Config, error) {
+	var config Config
+
+	body, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return config, nil
+	}
+	if err != nil {
+		return config, fmt.Errorf(err.Error())
+	}
+
+	err = yaml.UnmarshalStrict(body, &config)
+	if err != nil {
+		return config, fmt.Errorf("%s: %s", path, err.Error())
+	}
+
+	return config, nil
+}
+
+// Default loads the config file from DefaultPath and resolves the profile to use
+func Default() (Profile, error) {
+	config, err := Load(DefaultPath())
+	if err != nil {
+		return Profile{}, err
+	}
+
+	return config.Resolve("")
+}
+
+// Resolve returns the named profile with the shared aliases and environment overrides applied. An
+// empty name picks the profile from FILAMENT_PROFILE, then the config file, then DefaultProfile.
+func (c Config) Resolve(name string) (Profile, error) {
+	if name == "" {
+		name = os.Getenv(EnvProfile)
+	}
+	if name == "" {
+		name = c.Profile
+	}
+	if name == "" {
+		name = DefaultProfile
+	}
+
+	profile, ok := c.Profiles[name]
+	if !ok && name != DefaultProfile {
+		return Profile{}, fmt.Errorf("no profile named %q", name)
+	}
+	profile.Name = name
+
+	aliases := map[string]string{}
+	for alias, selector := range c.Aliases {
+		aliases[alias] = selector
+	}
+	for alias, selector := range profile.Aliases {
+		aliases[alias] = selector
+	}
+	profile.Aliases = aliases
+
+	err := profile.override()
+	if err != nil {
+		return Profile{}, err
+	}
+
+	return profile, nil
+}
+
+// override applies any settings given through the environment
+func (p *Profile) override() error {
+	if token := os.Getenv(EnvToken); token != "" {
+		p.Token = token
+	}
+	if baseURL := os.Getenv(EnvBaseURL); baseURL != "" {
+		p.BaseURL = baseURL
+	}
+	if selector := os.Getenv(EnvSelector); selector != "" {
+		p.Selector = selector
+	}
+
+	durations := map[string]*time.Duration{EnvTimeout: &p.Timeout, EnvRatePeriod: &p.RatePeriod}
+	for name, setting := range durations {
+		if value := os.Getenv(name); value != "" {
+			duration, err := time.ParseDuration(value)
+			if err != nil {
+				return fmt.Errorf("%s: %s", name, err.Error())
+			}
+			*setting = duration
+		}
+	}
+
+	numbers := map[string]*int{EnvRetries: &p.Retries, EnvRateLimit: &p.RateLimit}
+	for name, setting := range numbers {
+		if value := os.Getenv(name); value != "" {
+			number, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("%s: %s", name, err.Error())
+			}
+			*setting = number
+		}
+	}
+
+	return nil
+}
+
+// Client builds a lifx.Client from the profile. Rate limiting defaults to the LIFX API's limits.
+func (p Profile) Client() (*lifx.Client, error) {
+	if p.Token == "" {
+		return nil, fmt.Errorf("no access token for profile %q, set token in %s or %s", p.Name, DefaultPath(), EnvToken)
+	}
+
+	limit, period := p.RateLimit, p.RatePeriod
+	if limit <= 0 {
+		limit = lifx.DefaultRateLimit
+	}
+	if period <= 0 {
+		period = lifx.DefaultRatePeriod
+	}
+
+	client := &lifx.Client{
+		AccessToken: p.Token,
+		BaseURL:     p.BaseURL,
+		Retries:     p.Retries,
+		Limiter:     lifx.NewRateLimiter(limit, period),
+	}
+	if p.Timeout > 0 {
+		client.HTTPClient = &http.Client{Timeout: p.Timeout}
+	}
+
+	return client, nil
+}
+
+// Expand turns an alias into the selector it names. Selectors that aren't aliases are returned as
+// they are, and an empty selector becomes the profile's default selector, or "all".
+func (p Profile) Expand(selector string) string {
+	if selector == "" {
+		selector = p.Selector
+	}
+	if selector == "" {
+		return "all"
+	}
+
+	if expanded, ok := p.Aliases[selector]; ok {
+		return expanded
+	}
+
+	return selector
+}
@@ -0,0 +1,121 @@
+package config_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/panicpanicpanic/filament/config"
+)
+
+func TestConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	err := ioutil.WriteFile(path, []byte(`
+profile: home
+aliases:
+  desk: label:Desk Lamp
+  porch: label:Porch
+profiles:
+  home:
+    token: homeToken
+    timeout: 5s
+    retries: 2
+    rate_limit: 60
+    selector: desk
+    aliases:
+      porch: group:Outside
+  office:
+    token: officeToken
+    base_url: http://localhost:8080/v1
+`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := config.Load(path)
+	if err != nil {
+		t.Fatalf("it should have loaded the config, got %s", err)
+	}
+
+	t.Run("when no profile is asked for", func(t *testing.T) {
+		profile, err := cfg.Resolve("")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if profile.Name != "home" || profile.Token != "homeToken" || profile.Timeout != 5*time.Second {
+			t.Errorf("it should have used the config file's profile, got %+v", profile)
+		}
+		if profile.Expand("") != "label:Desk Lamp" {
+			t.Errorf("it should have expanded the default selector alias, got %s", profile.Expand(""))
+		}
+		if profile.Expand("porch") != "group:Outside" {
+			t.Errorf("it should have preferred the profile's alias, got %s", profile.Expand("porch"))
+		}
+		if profile.Expand("id:123") != "id:123" {
+			t.Errorf("it should have left plain selectors alone")
+		}
+	})
+
+	t.Run("when the environment overrides settings", func(t *testing.T) {
+		t.Setenv(config.EnvProfile, "office")
+		t.Setenv(config.EnvToken, "envToken")
+		t.Setenv(config.EnvRetries, "4")
+
+		profile, err := cfg.Resolve("")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if profile.Name != "office" || profile.Token != "envToken" || profile.Retries != 4 {
+			t.Errorf("it should have applied the environment, got %+v", profile)
+		}
+		if profile.Expand("") != "all" {
+			t.Errorf("it should have defaulted to all, got %s", profile.Expand(""))
+		}
+
+		client, err := profile.Client()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if client.URL() != "http://localhost:8080/v1" || client.Limiter == nil || client.Retries != 4 {
+			t.Errorf("it should have built the client from the profile, got %+v", client)
+		}
+	})
+
+	t.Run("when an override is malformed", func(t *testing.T) {
+		t.Setenv(config.EnvTimeout, "soon")
+		if _, err := cfg.Resolve("home"); err == nil {
+			t.Errorf("it should have rejected the timeout")
+		}
+	})
+
+	t.Run("when the profile does not exist", func(t *testing.T) {
+		if _, err := cfg.Resolve("cabin"); err == nil {
+			t.Errorf("it should have returned an error")
+		}
+	})
+
+	t.Run("when the profile has no token", func(t *testing.T) {
+		if _, err := (config.Profile{Name: "empty"}).Client(); err == nil {
+			t.Errorf("it should have returned an error")
+		}
+	})
+}
+
+func TestLoad(t *testing.T) {
+	t.Run("when the file does not exist", func(t *testing.T) {
+		cfg, err := config.Load(filepath.Join(t.TempDir(), "missing.yaml"))
+		if err != nil || len(cfg.Profiles) != 0 {
+			t.Errorf("it should have returned an empty config, got %+v %v", cfg, err)
+		}
+	})
+
+	t.Run("when the file has an unknown setting", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "config.yaml")
+		ioutil.WriteFile(path, []byte("profiles:\n  home:\n    tokn: typo\n"), 0600)
+
+		if _, err := config.Load(path); err == nil {
+			t.Errorf("it should have rejected the typo")
+		}
+	})
+}
@@ -0,0 +1,315 @@
+package filament
+
+import (
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/panicpanicpanic/filament/device"
+	"github.com/panicpanicpanic/filament/lifx"
+)
+
+// Kelvin limits assumed for lights that don't report their own
+const (
+	minKelvin = 1500
+	maxKelvin = 9000
+)
+
+// defaultKnobWindow is how long a Knob waits for more turns before sending
+const defaultKnobWindow = 150 * time.Millisecond
+
+// Delta is a typed StateDelta payload. Fields left at zero aren't changed.
+type Delta struct {
+	Brightness float64
+	Kelvin     float64
+	Hue        float64
+	Saturation float64
+
+	// Duration is how long the change takes, in seconds
+	Duration float64
+}
+
+// Brighten returns a Delta raising brightness by amount, between 0 and 1
+func Brighten(amount float64) Delta {
+	return Delta{Brightness: amount}
+}
+
+// Dim returns a Delta lowering brightness by amount, between 0 and 1
+func Dim(amount float64) Delta {
+	return Delta{Brightness: -amount}
+}
+
+// Warmer returns a Delta lowering the color temperature by kelvin
+func Warmer(kelvin float64) Delta {
+	return Delta{Kelvin: -kelvin}
+}
+
+// Cooler returns a Delta raising the color temperature by kelvin
+func Cooler(kelvin float64) Delta {
+	return Delta{Kelvin: kelvin}
+}
+
+// ShiftHue returns a Delta rotating the hue by degrees. LIFX wraps the hue around, so it is never clamped.
+func ShiftHue(degrees float64) Delta {
+	return Delta{Hue: degrees}
+}
+
+// Add combines two Deltas, keeping the longer Duration
+func (d Delta) Add(other Delta) Delta {
+	return Delta{
+		Brightness: d.Brightness + other.Brightness,
+		Kelvin:     d.Kelvin + other.Kelvin,
+		Hue:        d.Hue + other.Hue,
+		Saturation: d.Saturation + other.Saturation,
+		Duration:   math.Max(d.Duration, other.Duration),
+	}
+}
+
+// IsZero reports whether the Delta changes nothing
+func (d Delta) IsZero() bool {
+	return d.Brightness == 0 && d.Kelvin == 0 && d.Hue == 0 && d.Saturation == 0
+}
+
+// Payload returns the Delta as the payload StateDelta expects
+func (d Delta) Payload() map[string]interface{} {
+	payload := map[string]interface{}{}
+
+	fields := map[string]float64{
+		"brightness": d.Brightness,
+		"kelvin":     math.Floor(d.Kelvin + 0.5),
+		"hue":        d.Hue,
+		"saturation": d.Saturation,
+		"duration":   d.Duration,
+	}
+	for field, value := range fields {
+		if value != 0 {
+			payload[field] = value
+		}
+	}
+
+	return payload
+}
+
+// Clamp shrinks the Delta so it takes the Device no further than its valid ranges: brightness and
+// saturation between 0 and 1, and kelvin within what the Device supports
+func (d Delta) Clamp(light device.Device) Delta {
+	low, high := float64(light.Product.Capabilities.MinKelvin), float64(light.Product.Capabilities.MaxKelvin)
+	if low == 0 {
+		low = minKelvin
+	}
+	if high == 0 {
+		high = maxKelvin
+	}
+
+	return d.clampWithin(light, low, high)
+}
+
+// clampWithin clamps the Delta as Clamp does, with kelvin kept between low and high
+func (d Delta) clampWithin(light device.Device, low, high float64) Delta {
+	d.Brightness = clamp(light.Brightness, d.Brightness, 0, 1)
+	d.Saturation = clamp(light.Color.Saturation, d.Saturation, 0, 1)
+	if light.Color.Kelvin != 0 {
+		d.Kelvin = clamp(light.Color.Kelvin, d.Kelvin, low, high)
+	}
+
+	return d
+}
+
+// ApplyDelta sends delta to the lights within selector. When devices, such as a cached GetLights, are
+// given, the delta is clamped for each targeted light first.
+//
+// A selector made only of cached ids is fully known, so its lights are sent the amount each can take,
+// lights needing different amounts are sent separate requests, and lights already at their limit aren't
+// sent anything. Any other selector may reach lights missing from devices, so the delta goes out as is
+// to selector, relying on LIFX clipping brightness and saturation between 0 and 1 and kelvin between
+// 1500 and 9000; cached lights with a narrower kelvin range are then sent the correction that takes
+// them back within it.
+func ApplyDelta(client *lifx.Client, selector string, delta Delta, devices []device.Device) (lifx.Response, error) {
+	if selector == "" {
+		selector = "all"
+	}
+
+	targets := device.Filter(devices, selector)
+	if len(targets) == 0 {
+		return StateDelta(client, selector, delta.Payload())
+	}
+
+	var response lifx.Response
+	adjust := func(light device.Device) Delta {
+		return delta.Clamp(light)
+	}
+
+	known := onlyIDs(selector, targets)
+	if !known {
+		var err error
+		response, err = StateDelta(client, selector, delta.Payload())
+		if err != nil {
+			return response, err
+		}
+
+		adjust = func(light device.Device) Delta {
+			clipped := delta.clampWithin(light, minKelvin, maxKelvin)
+			return Delta{Kelvin: delta.Clamp(light).Kelvin - clipped.Kelvin, Duration: delta.Duration}
+		}
+	}
+
+	// Group the lights by the delta they still need, so most calls need a single request
+	var keys []string
+	groups := map[string][]string{}
+	deltas := map[string]Delta{}
+	for _, light := range targets {
+		adjusted := adjust(light)
+		if adjusted.IsZero() {
+			continue
+		}
+
+		key := stateKey(adjusted.Payload())
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], "id:"+light.ID+zoneSuffix(light, selector))
+		deltas[key] = adjusted
+	}
+
+	for _, key := range keys {
+		// A selector covering every targeted light keeps the request readable in logs
+		target := strings.Join(groups[key], ",")
+		if known && len(keys) == 1 && len(groups[key]) == len(targets) {
+			target = selector
+		}
+
+		result, err := StateDelta(client, target, deltas[key].Payload())
+		response.Results = append(response.Results, result.Results...)
+		if err != nil {
+			return response, err
+		}
+	}
+
+	return response, nil
+}
+
+// onlyIDs reports whether every part of selector is the id of one of lights
+func onlyIDs(selector string, lights []device.Device) bool {
+	for _, part := range strings.Split(selector, ",") {
+		part = device.SelectorBase(strings.TrimSpace(part))
+		if !strings.HasPrefix(part, "id:") || len(device.Filter(lights, part)) == 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// zoneSuffix returns the zone or tile suffix, such as "|0-5", of the part of selector matching light
+func zoneSuffix(light device.Device, selector string) string {
+	for _, part := range strings.Split(selector, ",") {
+		part = strings.TrimSpace(part)
+		if light.Matches(part) {
+			return part[len(device.SelectorBase(part)):]
+		}
+	}
+
+	return ""
+}
+
+// Knob coalesces rapid Turns, such as from a dimmer knob or key repeat, into a single StateDelta sent
+// once the Knob has been still for Window. When Devices is set the deltas are clamped, and Devices is
+// kept up to date with what was sent.
+type Knob struct {
+	Client   *lifx.Client
+	Selector string
+	Devices  []device.Device
+	Window   time.Duration
+
+	// OnSend, if set, is called with the result of every request the Knob sends
+	OnSend func(lifx.Response, error)
+
+	mutex   sync.Mutex
+	sending sync.Mutex
+	pending Delta
+	timer   *time.Timer
+}
+
+// Turn adds delta to what the Knob will send, restarting the wait for the Knob to be still
+func (k *Knob) Turn(delta Delta) {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+
+	k.pending = k.pending.Add(delta)
+
+	window := k.Window
+	if window <= 0 {
+		window = defaultKnobWindow
+	}
+
+	if k.timer != nil {
+		k.timer.Stop()
+	}
+	k.timer = time.AfterFunc(window, func() {
+		k.Flush()
+	})
+}
+
+// Flush sends what the Knob has been turned by straight away
+func (k *Knob) Flush() (lifx.Response, error) {
+	// Sends are made one at a time so the cached Devices clamp every delta correctly
+	k.sending.Lock()
+	defer k.sending.Unlock()
+
+	k.mutex.Lock()
+	delta := k.pending
+	k.pending = Delta{}
+	if k.timer != nil {
+		k.timer.Stop()
+		k.timer = nil
+	}
+	devices := k.Devices
+	k.mutex.Unlock()
+
+	if delta.IsZero() {
+		return lifx.Response{}, nil
+	}
+
+	client := *k.Client
+	response, err := ApplyDelta(&client, k.Selector, delta, devices)
+
+	if err == nil && devices != nil {
+		k.mutex.Lock()
+		k.Devices = applied(devices, k.Selector, delta)
+		k.mutex.Unlock()
+	}
+	if k.OnSend != nil {
+		k.OnSend(response, err)
+	}
+
+	return response, err
+}
+
+// applied returns a copy of devices with delta applied to the lights within selector
+func applied(devices []device.Device, selector string, delta Delta) []device.Device {
+	updated := make([]device.Device, len(devices))
+	copy(updated, devices)
+
+	for i := range updated {
+		light := &updated[i]
+		if !light.Matches(selector) {
+			continue
+		}
+
+		clamped := delta.Clamp(*light)
+		light.Brightness += clamped.Brightness
+		light.Color.Saturation += clamped.Saturation
+		light.Color.Hue = math.Mod(math.Mod(light.Color.Hue+clamped.Hue, 360)+360, 360)
+		if light.Color.Kelvin != 0 {
+			light.Color.Kelvin += math.Floor(clamped.Kelvin + 0.5)
+		}
+	}
+
+	return updated
+}
+
+// clamp shrinks change so current+change stays within low and high
+func clamp(current, change, low, high float64) float64 {
+	return math.Max(low, math.Min(high, current+change)) - current
+}
@@ -0,0 +1,172 @@
+package filament_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/panicpanicpanic/filament"
+	"github.com/panicpanicpanic/filament/device"
+	"github.com/panicpanicpanic/filament/lifx"
+)
+
+func TestDeltaClamp(t *testing.T) {
+	light := device.Device{
+		Brightness: 0.9,
+		Color:      device.Color{Kelvin: 6000, Saturation: 0.5},
+		Product:    device.Product{Capabilities: device.Capabilities{MinKelvin: 2500, MaxKelvin: 6500}},
+	}
+
+	t.Run("when brightening past full", func(t *testing.T) {
+		clamped := filament.Brighten(0.5).Clamp(light)
+		if clamped.Brightness < 0.0999 || clamped.Brightness > 0.1001 {
+			t.Errorf("it should have clamped to 0.1, got %v", clamped.Brightness)
+		}
+	})
+
+	t.Run("when cooling past the light's kelvin limit", func(t *testing.T) {
+		clamped := filament.Cooler(1000).Clamp(light)
+		if clamped.Kelvin != 500 {
+			t.Errorf("it should have clamped to 500, got %v", clamped.Kelvin)
+		}
+	})
+
+	t.Run("when shifting the hue", func(t *testing.T) {
+		clamped := filament.ShiftHue(400).Clamp(light)
+		if clamped.Hue != 400 {
+			t.Errorf("it should have left the hue alone, got %v", clamped.Hue)
+		}
+	})
+}
+
+func TestApplyDelta(t *testing.T) {
+	var mutex sync.Mutex
+	var requests []string
+	var payloads []map[string]interface{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&payload)
+
+		mutex.Lock()
+		requests = append(requests, r.URL.Path)
+		payloads = append(payloads, payload)
+		mutex.Unlock()
+
+		w.WriteHeader(http.StatusMultiStatus)
+		w.Write([]byte(`{"results": [{"id": "d1", "status": "ok"}]}`))
+	}))
+	defer server.Close()
+
+	client := &lifx.Client{AccessToken: "someRandomToken", BaseURL: server.URL}
+	office := device.Group{Name: "Office"}
+	devices := []device.Device{
+		{ID: "d1", Group: office, Brightness: 0.5, Color: device.Color{Kelvin: 3500}},
+		{ID: "d2", Group: office, Brightness: 0.5, Color: device.Color{Kelvin: 3500}},
+		{ID: "d3", Group: office, Brightness: 1, Color: device.Color{Kelvin: 3500}},
+		{ID: "d4", Group: office, Brightness: 0.5, Color: device.Color{Kelvin: 6000},
+			Product: device.Product{Capabilities: device.Capabilities{MinKelvin: 2500, MaxKelvin: 6500}}},
+	}
+
+	reset := func() {
+		mutex.Lock()
+		requests, payloads = nil, nil
+		mutex.Unlock()
+	}
+
+	t.Run("when no devices are cached", func(t *testing.T) {
+		reset()
+		filament.ApplyDelta(client, "group:Office", filament.Brighten(0.8), nil)
+		if len(requests) != 1 || requests[0] != "/lights/group:Office/state/delta" || payloads[0]["brightness"] != 0.8 {
+			t.Errorf("it should have sent the delta as is, got %v %v", requests, payloads)
+		}
+	})
+
+	t.Run("when some lights are already at their limit", func(t *testing.T) {
+		reset()
+		filament.ApplyDelta(client, "id:d1,id:d2,id:d3", filament.Brighten(0.8), devices)
+		if len(requests) != 1 || requests[0] != "/lights/id:d1,id:d2/state/delta" || payloads[0]["brightness"] != 0.5 {
+			t.Errorf("it should have clamped the delta and skipped the full light, got %v %v", requests, payloads)
+		}
+	})
+
+	t.Run("when every light named is already at its limit", func(t *testing.T) {
+		reset()
+		filament.ApplyDelta(client, "id:d3", filament.Brighten(0.8), devices)
+		if len(requests) != 0 {
+			t.Errorf("it should have sent nothing, got %v %v", requests, payloads)
+		}
+	})
+
+	t.Run("when lights in the selector may be missing from the cache", func(t *testing.T) {
+		reset()
+		filament.ApplyDelta(client, "group:Office", filament.Brighten(0.8), devices)
+		if len(requests) != 1 || requests[0] != "/lights/group:Office/state/delta" || payloads[0]["brightness"] != 0.8 {
+			t.Errorf("it should have sent the delta to the original selector for LIFX to clip, got %v %v", requests, payloads)
+		}
+	})
+
+	t.Run("when a light's kelvin range is narrower than LIFX clips to", func(t *testing.T) {
+		reset()
+		filament.ApplyDelta(client, "group:Office|0-3", filament.Cooler(1000), devices)
+		if len(requests) != 2 || requests[0] != "/lights/group:Office|0-3/state/delta" || payloads[0]["kelvin"] != 1000.0 {
+			t.Fatalf("it should have sent the delta to the original selector first, got %v %v", requests, payloads)
+		}
+		if requests[1] != "/lights/id:d4|0-3/state/delta" || payloads[1]["kelvin"] != -500.0 {
+			t.Errorf("it should have corrected the narrower light, keeping the zone suffix, got %v %v", requests, payloads)
+		}
+	})
+
+	t.Run("when cached lights need different amounts within zones", func(t *testing.T) {
+		reset()
+		filament.ApplyDelta(client, "id:d1|0-3,id:d3|4", filament.Brighten(0.8), devices)
+		if len(requests) != 1 || requests[0] != "/lights/id:d1|0-3/state/delta" || payloads[0]["brightness"] != 0.5 {
+			t.Errorf("it should have kept the zone suffix, got %v %v", requests, payloads)
+		}
+	})
+
+	t.Run("when every light can take the same delta", func(t *testing.T) {
+		reset()
+		filament.ApplyDelta(client, "group:Office", filament.Warmer(500), devices)
+		if len(requests) != 1 || requests[0] != "/lights/group:Office/state/delta" || payloads[0]["kelvin"] != -500.0 {
+			t.Errorf("it should have kept the original selector, got %v %v", requests, payloads)
+		}
+	})
+
+	t.Run("when a knob is turned quickly", func(t *testing.T) {
+		reset()
+		sent := make(chan error, 1)
+		knob := &filament.Knob{
+			Client:   client,
+			Selector: "id:d1",
+			Devices:  devices,
+			Window:   20 * time.Millisecond,
+			OnSend:   func(_ lifx.Response, err error) { sent <- err },
+		}
+
+		for i := 0; i < 8; i++ {
+			knob.Turn(filament.Brighten(0.1))
+		}
+
+		select {
+		case err := <-sent:
+			if err != nil {
+				t.Fatal(err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("it should have sent the turns")
+		}
+
+		mutex.Lock()
+		defer mutex.Unlock()
+		if len(requests) != 1 || payloads[0]["brightness"] != 0.5 {
+			t.Errorf("it should have sent one clamped request, got %v %v", requests, payloads)
+		}
+		if knob.Devices[0].Brightness != 1 {
+			t.Errorf("it should have updated the cached brightness, got %v", knob.Devices[0].Brightness)
+		}
+	})
+}
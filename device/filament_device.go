@@ -1,12 +1,13 @@
 package device
 
 import (
+	"fmt"
 	"time"
 )
 
 // Device represents the core fields for a LIFX light source
 type Device struct {
-	ID               string    `json:"json"`
+	ID               string    `json:"id"`
 	UUID             string    `json:"uuid"`
 	Label            string    `json:"label"`
 	Connected        bool      `json:"connected"`
@@ -18,6 +19,9 @@ type Device struct {
 	Color            Color     `json:"color"`
 	Location         Location  `json:"location"`
 	Product          Product   `json:"product"`
+	Infrared         Infrared  `json:"infrared,omitempty"`
+	Zones            *Zones    `json:"zones,omitempty"`
+	Tiles            []Tile    `json:"tiles,omitempty"`
 }
 
 // Group represents which group a Device belongs to
@@ -34,6 +38,15 @@ type Color struct {
 	Name       string  `json:"name"`
 }
 
+// String returns the Color in the "hue:120 saturation:1 kelvin:3500" format the LIFX HTTP API accepts
+func (c Color) String() string {
+	value := fmt.Sprintf("hue:%g saturation:%g", c.Hue, c.Saturation)
+	if c.Kelvin > 0 {
+		value += fmt.Sprintf(" kelvin:%d", int(c.Kelvin))
+	}
+	return value
+}
+
 // Location represents what Location a Device belongs to
 type Location struct {
 	ID   string `json:"id"`
@@ -0,0 +1,32 @@
+package device_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/panicpanicpanic/filament/device"
+)
+
+func TestDeviceJSON(t *testing.T) {
+	t.Run("when decoding a light from the LIFX API", func(t *testing.T) {
+		var d device.Device
+
+		err := json.Unmarshal([]byte(`{"id": "d073d5000000", "uuid": "02ea5835", "label": "Desk"}`), &d)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d.ID != "d073d5000000" {
+			t.Errorf("it should have decoded the id field, got %q", d.ID)
+		}
+	})
+
+	t.Run("when encoding a light", func(t *testing.T) {
+		body, _ := json.Marshal(device.Device{ID: "d073d5000000"})
+
+		var fields map[string]interface{}
+		json.Unmarshal(body, &fields)
+		if fields["id"] != "d073d5000000" {
+			t.Errorf("it should have encoded the ID as id, got %s", body)
+		}
+	})
+}
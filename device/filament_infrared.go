@@ -0,0 +1,39 @@
+package device
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// Infrared is the brightness of a night vision bulb's infrared LEDs, from 0.0 to 1.0
+type Infrared float64
+
+// Validate returns an error if the Infrared level is outside 0.0 - 1.0
+func (i Infrared) Validate() error {
+	if i < 0 || i > 1 {
+		return fmt.Errorf("infrared must be between 0.0 and 1.0, got %g", float64(i))
+	}
+	return nil
+}
+
+// UnmarshalJSON accepts the infrared level as either a number or a quoted number, as LIFX sends both.
+// A null level leaves the Infrared as it was.
+func (i *Infrared) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+
+	var quoted string
+	if err := json.Unmarshal(data, &quoted); err == nil {
+		data = []byte(quoted)
+	}
+
+	value, err := strconv.ParseFloat(string(data), 64)
+	if err != nil {
+		return fmt.Errorf("invalid infrared level %s", data)
+	}
+
+	*i = Infrared(value)
+	return nil
+}
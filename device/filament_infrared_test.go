@@ -0,0 +1,44 @@
+package device_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/panicpanicpanic/filament/device"
+)
+
+func TestInfrared(t *testing.T) {
+	for body, expected := range map[string]device.Infrared{
+		`{"infrared": 0.25}`:   0.25,
+		`{"infrared": "0.75"}`: 0.75,
+		`{"infrared": 1}`:      1,
+		`{}`:                   0,
+		`{"infrared": null}`:   0,
+	} {
+		t.Run("when decoding "+body, func(t *testing.T) {
+			var d device.Device
+			if err := json.Unmarshal([]byte(body), &d); err != nil {
+				t.Fatal(err)
+			}
+			if d.Infrared != expected {
+				t.Errorf("it should have decoded %v, got %v", expected, d.Infrared)
+			}
+		})
+	}
+
+	t.Run("when the level is not a number", func(t *testing.T) {
+		var d device.Device
+		if err := json.Unmarshal([]byte(`{"infrared": "bright"}`), &d); err == nil {
+			t.Errorf("it should have returned an error")
+		}
+	})
+
+	t.Run("when validating levels", func(t *testing.T) {
+		if device.Infrared(0.5).Validate() != nil {
+			t.Errorf("it should have accepted 0.5")
+		}
+		if device.Infrared(-0.1).Validate() == nil || device.Infrared(1.1).Validate() == nil {
+			t.Errorf("it should have rejected levels outside 0.0 - 1.0")
+		}
+	})
+}
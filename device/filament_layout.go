@@ -0,0 +1,50 @@
+package device
+
+// Zones represents the individually addressable zones of a multizone Device, such as a LIFX Z or Beam
+type Zones struct {
+	Count int    `json:"count"`
+	Zones []Zone `json:"zones"`
+}
+
+// Zone represents the current color of a single zone
+type Zone struct {
+	Zone       int     `json:"zone"`
+	Hue        float64 `json:"hue"`
+	Saturation float64 `json:"saturation"`
+	Kelvin     float64 `json:"kelvin"`
+	Brightness float64 `json:"brightness"`
+}
+
+// Tile represents a single tile in a chained Device, such as the LIFX Tile
+type Tile struct {
+	Index  int     `json:"index"`
+	UserX  float64 `json:"user_x"`
+	UserY  float64 `json:"user_y"`
+	Width  int     `json:"width"`
+	Height int     `json:"height"`
+}
+
+// ZoneCount returns how many zones a Device has, or 0 if it is not multizone
+func (d Device) ZoneCount() int {
+	if d.Zones == nil {
+		return 0
+	}
+	if d.Zones.Count > 0 {
+		return d.Zones.Count
+	}
+	return len(d.Zones.Zones)
+}
+
+// TileCount returns how many tiles a Device has in its chain, or 0 if it is not chained
+func (d Device) TileCount() int {
+	return len(d.Tiles)
+}
+
+// Color returns the Zone's color
+func (z Zone) Color() Color {
+	return Color{
+		Hue:        z.Hue,
+		Saturation: z.Saturation,
+		Kelvin:     z.Kelvin,
+	}
+}
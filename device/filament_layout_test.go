@@ -0,0 +1,54 @@
+package device_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/panicpanicpanic/filament/device"
+)
+
+func TestLayout(t *testing.T) {
+	t.Run("when a multizone light is listed", func(t *testing.T) {
+		var d device.Device
+		err := json.Unmarshal([]byte(`{
+			"id": "d073d5",
+			"zones": {"count": 16, "zones": [{"zone": 0, "hue": 120, "saturation": 1, "kelvin": 3500, "brightness": 0.5}]}
+		}`), &d)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if d.ZoneCount() != 16 {
+			t.Errorf("it should have used the zone count, got %d", d.ZoneCount())
+		}
+		if color := d.Zones.Zones[0].Color(); color.Hue != 120 || color.Kelvin != 3500 {
+			t.Errorf("it should have decoded the zone color, got %+v", color)
+		}
+	})
+
+	t.Run("when the zone count is missing", func(t *testing.T) {
+		d := device.Device{Zones: &device.Zones{Zones: make([]device.Zone, 8)}}
+		if d.ZoneCount() != 8 {
+			t.Errorf("it should have counted the zones, got %d", d.ZoneCount())
+		}
+	})
+
+	t.Run("when a chained light is listed", func(t *testing.T) {
+		var d device.Device
+		err := json.Unmarshal([]byte(`{"tiles": [{"index": 0, "user_x": 0, "user_y": 1.5, "width": 8, "height": 8}, {"index": 1}]}`), &d)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if d.TileCount() != 2 || d.Tiles[0].Width != 8 || d.Tiles[0].UserY != 1.5 {
+			t.Errorf("it should have decoded the tiles, got %+v", d.Tiles)
+		}
+	})
+
+	t.Run("when a light has no zones or tiles", func(t *testing.T) {
+		var d device.Device
+		if d.ZoneCount() != 0 || d.TileCount() != 0 {
+			t.Errorf("it should have reported no zones or tiles")
+		}
+	})
+}
@@ -0,0 +1,76 @@
+package device
+
+import (
+	"strings"
+)
+
+// Matches reports whether the Device is targeted by a LIFX selector such as "label:Desk Lamp" or
+// "group:Office,id:d073d5000000". Zone and tile suffixes ("|0-5") are ignored, and scene selectors
+// never match because scenes can't be resolved from a Device alone.
+func (d Device) Matches(selector string) bool {
+	for _, part := range strings.Split(selector, ",") {
+		part = SelectorBase(strings.TrimSpace(part))
+
+		if part == "all" {
+			return true
+		}
+
+		kind, value := splitSelector(part)
+		switch kind {
+		case "id":
+			if d.ID == value {
+				return true
+			}
+		case "label":
+			if d.Label == value {
+				return true
+			}
+		case "group_id":
+			if d.Group.ID == value {
+				return true
+			}
+		case "group":
+			if d.Group.Name == value {
+				return true
+			}
+		case "location_id":
+			if d.Location.ID == value {
+				return true
+			}
+		case "location":
+			if d.Location.Name == value {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Filter returns the Devices targeted by the given selector
+func Filter(devices []Device, selector string) []Device {
+	var matched []Device
+
+	for _, d := range devices {
+		if d.Matches(selector) {
+			matched = append(matched, d)
+		}
+	}
+
+	return matched
+}
+
+// SelectorBase strips any zone or tile suffix from a single selector, turning "id:abc|0-5" into "id:abc"
+func SelectorBase(selector string) string {
+	if i := strings.Index(selector, "|"); i >= 0 {
+		return selector[:i]
+	}
+	return selector
+}
+
+func splitSelector(selector string) (string, string) {
+	if i := strings.Index(selector, ":"); i >= 0 {
+		return selector[:i], selector[i+1:]
+	}
+	return selector, ""
+}
@@ -0,0 +1,137 @@
+package effect
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/panicpanicpanic/filament"
+	"github.com/panicpanicpanic/filament/lifx"
+)
+
+// Waveforms LIFX can run
+const (
+	// Pulse switches abruptly between FromColor and Color
+	Pulse = "pulse"
+
+	// Breathe fades smoothly between FromColor and Color
+	Breathe = "breathe"
+)
+
+// Presets are ready made effects. Copy one and change its fields to adjust it, e.g.
+// effect.Alarm.WithColor("orange").
+var (
+	Heartbeat   = Request{Waveform: Breathe, Color: "red", Period: 0.8, Cycles: 6}.WithPeak(0.2)
+	Alarm       = Request{Waveform: Pulse, Color: "red", Period: 0.5, Cycles: 10}
+	Doorbell    = Request{Waveform: Pulse, Color: "white", Period: 0.3, Cycles: 3}
+	SlowBreathe = Request{Waveform: Breathe, Color: "blue", Period: 4, Cycles: 5}.WithPeak(0.5)
+)
+
+// Request is a typed PulseEffect or BreatheEffect payload
+type Request struct {
+	Waveform string
+
+	// Color is the color the effect switches or fades to. FromColor, if set, is the color it starts
+	// from instead of the lights' current color.
+	Color     string
+	FromColor string
+
+	// Period is the length of one cycle in seconds, and Cycles how many times it repeats
+	Period float64
+	Cycles float64
+
+	// Persist leaves the lights on the effect's last color instead of going back to how they were
+	Persist bool
+
+	// PowerOn, if set, decides whether lights that are off are turned on for the effect. LIFX turns
+	// them on when it isn't set.
+	PowerOn *bool
+
+	// Peak, if set, is where in each cycle a Breathe effect is brightest, between 0 and 1. LIFX uses
+	// 0.5 when it isn't set.
+	Peak *float64
+}
+
+// WithColor returns a copy of the Request using color
+func (r Request) WithColor(color string) Request {
+	r.Color = color
+	return r
+}
+
+// WithCycles returns a copy of the Request repeating cycles times
+func (r Request) WithCycles(cycles float64) Request {
+	r.Cycles = cycles
+	return r
+}
+
+// WithPeak returns a copy of the Request brightest at peak through each cycle
+func (r Request) WithPeak(peak float64) Request {
+	r.Peak = &peak
+	return r
+}
+
+// Validate checks the Request's fields are in the ranges LIFX accepts
+func (r Request) Validate() error {
+	if r.Waveform != Pulse && r.Waveform != Breathe {
+		return fmt.Errorf("waveform must be %q or %q, got %q", Pulse, Breathe, r.Waveform)
+	}
+	if r.Color == "" {
+		return fmt.Errorf("color is required")
+	}
+	if r.Period <= 0 {
+		return fmt.Errorf("period must be greater than 0, got %v", r.Period)
+	}
+	if r.Cycles <= 0 {
+		return fmt.Errorf("cycles must be greater than 0, got %v", r.Cycles)
+	}
+	if r.Peak == nil {
+		return nil
+	}
+	if *r.Peak < 0 || *r.Peak > 1 {
+		return fmt.Errorf("peak must be between 0 and 1, got %v", *r.Peak)
+	}
+	if r.Waveform != Breathe {
+		return fmt.Errorf("peak only applies to %s effects", Breathe)
+	}
+
+	return nil
+}
+
+// Duration estimates how long the effect runs for, so follow up actions can be scheduled after it
+func (r Request) Duration() time.Duration {
+	return time.Duration(r.Period * r.Cycles * float64(time.Second))
+}
+
+// Payload returns the Request as the payload PulseEffect and BreatheEffect expect
+func (r Request) Payload() map[string]interface{} {
+	payload := map[string]interface{}{
+		"color":   r.Color,
+		"period":  r.Period,
+		"cycles":  r.Cycles,
+		"persist": r.Persist,
+	}
+
+	if r.FromColor != "" {
+		payload["from_color"] = r.FromColor
+	}
+	if r.PowerOn != nil {
+		payload["power_on"] = *r.PowerOn
+	}
+	if r.Peak != nil {
+		payload["peak"] = *r.Peak
+	}
+
+	return payload
+}
+
+// Send validates the Request and runs it on the lights within selector
+func (r Request) Send(client *lifx.Client, selector string) (lifx.Response, error) {
+	err := r.Validate()
+	if err != nil {
+		return lifx.Response{}, err
+	}
+
+	if r.Waveform == Breathe {
+		return filament.BreatheEffect(client, selector, r.Payload())
+	}
+	return filament.PulseEffect(client, selector, r.Payload())
+}
@@ -0,0 +1,129 @@
+package effect_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/panicpanicpanic/filament/effect"
+	"github.com/panicpanicpanic/filament/lifx"
+)
+
+func TestPresets(t *testing.T) {
+	for name, preset := range map[string]effect.Request{
+		"heartbeat":    effect.Heartbeat,
+		"alarm":        effect.Alarm,
+		"doorbell":     effect.Doorbell,
+		"slow-breathe": effect.SlowBreathe,
+	} {
+		if err := preset.Validate(); err != nil {
+			t.Errorf("it should have a valid %s preset, got %s", name, err)
+		}
+	}
+
+	t.Run("when a preset is adjusted", func(t *testing.T) {
+		orange := effect.Alarm.WithColor("orange").WithCycles(4)
+		if orange.Color != "orange" || orange.Cycles != 4 || effect.Alarm.Color != "red" {
+			t.Errorf("it should have changed a copy of the preset, got %+v", orange)
+		}
+	})
+}
+
+func TestValidate(t *testing.T) {
+	valid := effect.Request{Waveform: effect.Breathe, Color: "red", Period: 1, Cycles: 1}
+
+	for description, request := range map[string]effect.Request{
+		"when the peak is above 1":      valid.WithPeak(1.5),
+		"when the peak is set on pulse": effect.Alarm.WithPeak(0.5),
+		"when cycles is zero":           {Waveform: effect.Breathe, Color: "red", Period: 1},
+		"when the period is negative":   {Waveform: effect.Breathe, Color: "red", Period: -1, Cycles: 1},
+		"when the waveform is unknown":  {Waveform: "strobe", Color: "red", Period: 1, Cycles: 1},
+		"when the color is missing":     {Waveform: effect.Pulse, Period: 1, Cycles: 1},
+	} {
+		t.Run(description, func(t *testing.T) {
+			if err := request.Validate(); err == nil {
+				t.Errorf("it should have returned an error")
+			}
+		})
+	}
+
+	if err := valid.Validate(); err != nil {
+		t.Errorf("it should have accepted a valid request, got %s", err)
+	}
+	if err := valid.WithPeak(0).Validate(); err != nil {
+		t.Errorf("it should have accepted a peak of 0, got %s", err)
+	}
+}
+
+func TestDuration(t *testing.T) {
+	if duration := effect.Alarm.Duration(); duration != 5*time.Second {
+		t.Errorf("it should have estimated 5s for the alarm, got %s", duration)
+	}
+	if duration := effect.Heartbeat.Duration(); duration != 4800*time.Millisecond {
+		t.Errorf("it should have estimated 4.8s for the heartbeat, got %s", duration)
+	}
+}
+
+func TestSend(t *testing.T) {
+	var path string
+	var payload map[string]interface{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		path = r.URL.Path
+		json.Unmarshal(body, &payload)
+		w.Write([]byte(`{"results": [{"id": "d1", "status": "ok"}]}`))
+	}))
+	defer server.Close()
+
+	client := &lifx.Client{AccessToken: "someRandomToken", BaseURL: server.URL}
+
+	t.Run("when sending a breathe preset", func(t *testing.T) {
+		off := false
+		request := effect.SlowBreathe
+		request.PowerOn = &off
+
+		_, err := request.Send(client, "label:Desk")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if path != "/lights/label:Desk/effects/breathe" {
+			t.Errorf("it should have posted to the breathe endpoint, got %s", path)
+		}
+		if payload["peak"] != 0.5 || payload["power_on"] != false || payload["color"] != "blue" {
+			t.Errorf("it should have sent the typed fields, got %v", payload)
+		}
+	})
+
+	t.Run("when the peak is 0", func(t *testing.T) {
+		_, err := effect.SlowBreathe.WithPeak(0).Send(client, "label:Desk")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if peak, ok := payload["peak"]; !ok || peak != 0.0 {
+			t.Errorf("it should have sent a peak of 0, got %v", payload)
+		}
+	})
+
+	t.Run("when the peak isn't set", func(t *testing.T) {
+		payload = nil
+		_, err := effect.Alarm.Send(client, "label:Desk")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := payload["peak"]; ok {
+			t.Errorf("it should have left the peak to LIFX, got %v", payload)
+		}
+	})
+
+	t.Run("when the request is invalid", func(t *testing.T) {
+		path = ""
+		_, err := effect.Alarm.WithCycles(0).Send(client, "all")
+		if err == nil || path != "" {
+			t.Errorf("it should have failed without calling LIFX")
+		}
+	})
+}
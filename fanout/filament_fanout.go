@@ -0,0 +1,189 @@
+package fanout
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/panicpanicpanic/filament"
+	"github.com/panicpanicpanic/filament/lifx"
+)
+
+// DefaultConcurrency is how many Tasks an Executor runs at once when Concurrency isn't set
+const DefaultConcurrency = 4
+
+// Mode decides what an Executor does when a Task fails
+type Mode int
+
+const (
+	// BestEffort runs every Task regardless of failures
+	BestEffort Mode = iota
+
+	// FailFast cancels the remaining Tasks as soon as one fails
+	FailFast
+)
+
+// Task is a single filament call to run, named so its Outcome can be found in a Report
+type Task struct {
+	Name string
+	Call func(client *lifx.Client) (lifx.Response, error)
+}
+
+// Outcome is the result of running a single Task
+type Outcome struct {
+	Name     string
+	Response lifx.Response
+	Err      error
+}
+
+// Report holds the Outcome of every Task, in the order the Tasks were given
+type Report struct {
+	Outcomes []Outcome
+}
+
+// Executor runs Tasks concurrently with its own copy of Client for each, so they all share the
+// Client's Limiter. If the Client has no Limiter, the Executor creates one with the LIFX defaults
+// the first time it runs and uses it for every Run after.
+type Executor struct {
+	Client      *lifx.Client
+	Concurrency int
+	Mode        Mode
+
+	once    sync.Once
+	limiter *lifx.RateLimiter
+}
+
+// Run runs every Task and returns their Outcomes. The error is nil only if every Task succeeded.
+func (e *Executor) Run(ctx context.Context, tasks []Task) (Report, error) {
+	report := Report{Outcomes: make([]Outcome, len(tasks))}
+
+	concurrency := e.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultConcurrency
+	}
+
+	// Running calls side by side without a Limiter would quickly exceed the LIFX rate limit. The
+	// Limiter has to outlive each Run, or back to back Runs would each get a fresh allowance.
+	template := *e.Client
+	if template.Limiter == nil {
+		e.once.Do(func() {
+			e.limiter = lifx.NewRateLimiter(lifx.DefaultRateLimit, lifx.DefaultRatePeriod)
+		})
+		template.Limiter = e.limiter
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, concurrency)
+
+	for i, task := range tasks {
+		report.Outcomes[i].Name = task.Name
+
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+		}
+
+		// A slot and a cancellation can arrive together, so check again before starting the Task
+		if ctx.Err() != nil {
+			report.Outcomes[i].Err = ctx.Err()
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int, task Task) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			client := template
+			client.Context = ctx
+
+			response, err := task.Call(&client)
+			report.Outcomes[i].Response = response
+			report.Outcomes[i].Err = err
+
+			if err != nil && e.Mode == FailFast {
+				cancel()
+			}
+		}(i, task)
+	}
+
+	wg.Wait()
+	return report, report.Err()
+}
+
+// Results returns the lifx.Results of every successful Task
+func (r Report) Results() []lifx.Result {
+	var results []lifx.Result
+
+	for _, outcome := range r.Outcomes {
+		results = append(results, outcome.Response.Results...)
+	}
+
+	return results
+}
+
+// Failed returns the Outcomes of the Tasks that failed or were cancelled
+func (r Report) Failed() []Outcome {
+	var failed []Outcome
+
+	for _, outcome := range r.Outcomes {
+		if outcome.Err != nil {
+			failed = append(failed, outcome)
+		}
+	}
+
+	return failed
+}
+
+// Err returns an error naming every failed Task, or nil if they all succeeded
+func (r Report) Err() error {
+	var messages []string
+
+	for _, outcome := range r.Failed() {
+		messages = append(messages, outcome.Name+": "+outcome.Err.Error())
+	}
+
+	if len(messages) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%d of %d tasks failed: %s", len(messages), len(r.Outcomes), strings.Join(messages, "; "))
+}
+
+// SetState returns a Task calling filament.SetState, named after the selector
+func SetState(selector string, payload interface{}) Task {
+	return Task{Name: selector, Call: func(client *lifx.Client) (lifx.Response, error) {
+		return filament.SetState(client, selector, payload)
+	}}
+}
+
+// StateDelta returns a Task calling filament.StateDelta, named after the selector
+func StateDelta(selector string, payload interface{}) Task {
+	return Task{Name: selector, Call: func(client *lifx.Client) (lifx.Response, error) {
+		return filament.StateDelta(client, selector, payload)
+	}}
+}
+
+// TogglePower returns a Task calling filament.TogglePower, named after the selector
+func TogglePower(selector string) Task {
+	return Task{Name: selector, Call: func(client *lifx.Client) (lifx.Response, error) {
+		return filament.TogglePower(client, selector)
+	}}
+}
+
+// PulseEffect returns a Task calling filament.PulseEffect, named after the selector
+func PulseEffect(selector string, payload interface{}) Task {
+	return Task{Name: selector, Call: func(client *lifx.Client) (lifx.Response, error) {
+		return filament.PulseEffect(client, selector, payload)
+	}}
+}
+
+// BreatheEffect returns a Task calling filament.BreatheEffect, named after the selector
+func BreatheEffect(selector string, payload interface{}) Task {
+	return Task{Name: selector, Call: func(client *lifx.Client) (lifx.Response, error) {
+		return filament.BreatheEffect(client, selector, payload)
+	}}
+}
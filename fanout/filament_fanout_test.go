@@ -0,0 +1,102 @@
+package fanout_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/panicpanicpanic/filament/fanout"
+	"github.com/panicpanicpanic/filament/lifx"
+)
+
+func TestExecutor(t *testing.T) {
+	var mu sync.Mutex
+	var running, peak, calls int
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		running++
+		calls++
+		if running > peak {
+			peak = running
+		}
+		mu.Unlock()
+
+		time.Sleep(20 * time.Millisecond)
+
+		mu.Lock()
+		running--
+		mu.Unlock()
+
+		if strings.Contains(r.URL.Path, "broken") {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusMultiStatus)
+		w.Write([]byte(`{"results": [{"id": "1", "status": "ok"}]}`))
+	}))
+	defer server.Close()
+
+	client := lifx.Client{
+		AccessToken: "someRandomToken",
+		BaseURL:     server.URL,
+		Limiter:     lifx.NewRateLimiter(1000, time.Second),
+	}
+
+	t.Run("when running in best effort mode", func(t *testing.T) {
+		var tasks []fanout.Task
+		for i := 0; i < 8; i++ {
+			tasks = append(tasks, fanout.PulseEffect(fmt.Sprintf("id:%d", i), map[string]interface{}{"color": "red"}))
+		}
+		tasks[3] = fanout.TogglePower("id:broken")
+
+		executor := fanout.Executor{Client: &client, Concurrency: 3}
+		report, err := executor.Run(context.Background(), tasks)
+
+		if err == nil || len(report.Failed()) != 1 || report.Failed()[0].Name != "id:broken" {
+			t.Errorf("it should have reported only the broken task, got %v", err)
+		}
+		if len(report.Results()) != 7 {
+			t.Errorf("it should have collected the results of the other tasks, got %d", len(report.Results()))
+		}
+		if peak > 3 {
+			t.Errorf("it should have run at most 3 tasks at once, got %d", peak)
+		}
+	})
+
+	t.Run("when running in fail fast mode", func(t *testing.T) {
+		calls = 0
+		tasks := []fanout.Task{fanout.TogglePower("id:broken")}
+		for i := 0; i < 10; i++ {
+			tasks = append(tasks, fanout.TogglePower(fmt.Sprintf("id:%d", i)))
+		}
+
+		executor := fanout.Executor{Client: &client, Concurrency: 1, Mode: fanout.FailFast}
+		report, _ := executor.Run(context.Background(), tasks)
+
+		if calls != 1 || len(report.Failed()) != len(tasks) {
+			t.Errorf("it should have cancelled every task after the first failure, got %d calls", calls)
+		}
+	})
+
+	t.Run("when the client has no Limiter", func(t *testing.T) {
+		var limiters []*lifx.RateLimiter
+		record := fanout.Task{Name: "record", Call: func(client *lifx.Client) (lifx.Response, error) {
+			limiters = append(limiters, client.Limiter)
+			return lifx.Response{}, nil
+		}}
+
+		executor := fanout.Executor{Client: &lifx.Client{AccessToken: "someRandomToken"}, Concurrency: 1}
+		executor.Run(context.Background(), []fanout.Task{record})
+		executor.Run(context.Background(), []fanout.Task{record})
+
+		if len(limiters) != 2 || limiters[0] == nil || limiters[0] != limiters[1] {
+			t.Errorf("it should have shared one Limiter across Runs, got %v", limiters)
+		}
+	})
+}
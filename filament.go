@@ -7,6 +7,7 @@ import (
 	"github.com/panicpanicpanic/filament/device"
 	"github.com/panicpanicpanic/filament/lifx"
 	"github.com/panicpanicpanic/filament/service"
+	"github.com/panicpanicpanic/filament/validation"
 )
 
 // GetLights returns []device.Device that belong to your LIFX account
@@ -21,11 +22,11 @@ func GetLights(client *lifx.Client, selector string) ([]device.Device, error) {
 	}
 
 	// In order to access LIFX HTTP API, you must pass a valid AccessToken and URL path
-	client.Endpoint = lifx.LIFXAPIURL + "/lights/" + selector
+	client.Endpoint = client.URL() + "/lights/" + selector
 
 	body, err = service.Get(client)
 	if err != nil {
-		return devices, fmt.Errorf(err.Error())
+		return devices, err
 	}
 
 	err = json.Unmarshal(body, &devices)
@@ -44,11 +45,11 @@ func GetScenes(client *lifx.Client) ([]device.Scene, error) {
 	var err error
 
 	// In order to access LIFX HTTP API, you must pass a valid AccessToken and Endpoint
-	client.Endpoint = lifx.LIFXAPIURL + "/scenes"
+	client.Endpoint = client.URL() + "/scenes"
 
 	body, err = service.Get(client)
 	if err != nil {
-		return scenes, fmt.Errorf(err.Error())
+		return scenes, err
 	}
 
 	err = json.Unmarshal(body, &scenes)
@@ -67,11 +68,11 @@ func ValidateColor(client *lifx.Client, color string) (device.Color, error) {
 	var err error
 
 	// In order to access LIFX HTTP API, you must pass a valid AccessToken and Endpoint
-	client.Endpoint = lifx.LIFXAPIURL + "/color?string=" + color
+	client.Endpoint = client.URL() + "/color?string=" + color
 
 	body, err = service.Get(client)
 	if err != nil {
-		return deviceColor, fmt.Errorf(err.Error())
+		return deviceColor, err
 	}
 
 	err = json.Unmarshal(body, &deviceColor)
@@ -94,12 +95,25 @@ func SetState(client *lifx.Client, selector string, payload interface{}) (lifx.R
 		selector = "all"
 	}
 
+	// In strict mode, make sure every targeted device can handle the payload before sending it
+	if client.Strict {
+		report, err := strictReport(client, func(devices []device.Device) (validation.Report, error) {
+			return validation.State(devices, selector, payload)
+		})
+		if err != nil {
+			return response, err
+		}
+		if !report.Valid() {
+			return response, report.Err()
+		}
+	}
+
 	// In order to access LIFX HTTP API, you must pass a valid AccessToken and Endpoint
-	client.Endpoint = lifx.LIFXAPIURL + "/lights/" + selector + "/state"
+	client.Endpoint = client.URL() + "/lights/" + selector + "/state"
 
 	body, err = service.Put(client, payload)
 	if err != nil {
-		return response, fmt.Errorf(err.Error())
+		return response, err
 	}
 
 	err = json.Unmarshal(body, &response)
@@ -117,12 +131,25 @@ func SetStates(client *lifx.Client, payload interface{}) (lifx.Response, error)
 	var err error
 	var response lifx.Response
 
+	// In strict mode, make sure every targeted device can handle the payload before sending it
+	if client.Strict {
+		report, err := strictReport(client, func(devices []device.Device) (validation.Report, error) {
+			return validation.States(devices, payload)
+		})
+		if err != nil {
+			return response, err
+		}
+		if !report.Valid() {
+			return response, report.Err()
+		}
+	}
+
 	// In order to access LIFX HTTP API, you must pass a valid AccessToken and Endpoint
-	client.Endpoint = lifx.LIFXAPIURL + "/lights/states"
+	client.Endpoint = client.URL() + "/lights/states"
 
 	body, err = service.Put(client, payload)
 	if err != nil {
-		return response, fmt.Errorf(err.Error())
+		return response, err
 	}
 
 	err = json.Unmarshal(body, &response)
@@ -141,11 +168,11 @@ func ActivateScene(client *lifx.Client, sceneUUID string, payload interface{}) (
 	var response lifx.Response
 
 	// In order to access LIFX HTTP API, you must pass a valid AccessToken and Endpoint
-	client.Endpoint = lifx.LIFXAPIURL + "/scenes/scene_id:" + sceneUUID + "/activate"
+	client.Endpoint = client.URL() + "/scenes/scene_id:" + sceneUUID + "/activate"
 
 	body, err = service.Put(client, payload)
 	if err != nil {
-		return response, fmt.Errorf(err.Error())
+		return response, err
 	}
 
 	err = json.Unmarshal(body, &response)
@@ -164,11 +191,11 @@ func Cycle(client *lifx.Client, selector string, payload interface{}) (lifx.Resp
 	var response lifx.Response
 
 	// In order to access LIFX HTTP API, you must pass a valid AccessToken and Endpoint
-	client.Endpoint = lifx.LIFXAPIURL + "/lights/" + selector + "/cycle"
+	client.Endpoint = client.URL() + "/lights/" + selector + "/cycle"
 
 	body, err = service.Post(client, payload)
 	if err != nil {
-		return response, fmt.Errorf(err.Error())
+		return response, err
 	}
 
 	err = json.Unmarshal(body, &response)
@@ -187,11 +214,11 @@ func PulseEffect(client *lifx.Client, selector string, payload interface{}) (lif
 	var response lifx.Response
 
 	// In order to access LIFX HTTP API, you must pass a valid AccessToken and Endpoint
-	client.Endpoint = lifx.LIFXAPIURL + "/lights/" + selector + "/effects/pulse"
+	client.Endpoint = client.URL() + "/lights/" + selector + "/effects/pulse"
 
 	body, err = service.Post(client, payload)
 	if err != nil {
-		return response, fmt.Errorf(err.Error())
+		return response, err
 	}
 
 	err = json.Unmarshal(body, &response)
@@ -210,11 +237,11 @@ func BreatheEffect(client *lifx.Client, selector string, payload interface{}) (l
 	var response lifx.Response
 
 	// In order to access LIFX HTTP API, you must pass a valid AccessToken and Endpoint
-	client.Endpoint = lifx.LIFXAPIURL + "/lights/" + selector + "/effects/pulse"
+	client.Endpoint = client.URL() + "/lights/" + selector + "/effects/breathe"
 
 	body, err = service.Post(client, payload)
 	if err != nil {
-		return response, fmt.Errorf(err.Error())
+		return response, err
 	}
 
 	err = json.Unmarshal(body, &response)
@@ -237,11 +264,11 @@ func TogglePower(client *lifx.Client, selector string) (lifx.Response, error) {
 		selector = "all"
 	}
 	// In order to access LIFX HTTP API, you must pass a valid AccessToken and Endpoint
-	client.Endpoint = lifx.LIFXAPIURL + "/lights/" + selector + "/toggle"
+	client.Endpoint = client.URL() + "/lights/" + selector + "/toggle"
 
 	body, err = service.Post(client, nil)
 	if err != nil {
-		return response, fmt.Errorf(err.Error())
+		return response, err
 	}
 
 	err = json.Unmarshal(body, &response)
@@ -265,11 +292,11 @@ func StateDelta(client *lifx.Client, selector string, payload interface{}) (lifx
 	}
 
 	// In order to access LIFX HTTP API, you must pass a valid AccessToken and Endpoint
-	client.Endpoint = lifx.LIFXAPIURL + "/lights/" + selector + "/state/delta"
+	client.Endpoint = client.URL() + "/lights/" + selector + "/state/delta"
 
 	body, err = service.Post(client, payload)
 	if err != nil {
-		return response, fmt.Errorf(err.Error())
+		return response, err
 	}
 
 	err = json.Unmarshal(body, &response)
@@ -0,0 +1,37 @@
+package filament_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/panicpanicpanic/filament"
+	"github.com/panicpanicpanic/filament/lifx"
+)
+
+func TestEffects(t *testing.T) {
+	var path string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		w.WriteHeader(http.StatusMultiStatus)
+		w.Write([]byte(`{"results": [{"id": "d1", "status": "ok"}]}`))
+	}))
+	defer server.Close()
+
+	client := &lifx.Client{AccessToken: "someRandomToken", BaseURL: server.URL}
+
+	t.Run("when pulsing", func(t *testing.T) {
+		_, err := filament.PulseEffect(client, "all", map[string]interface{}{"color": "red"})
+		if err != nil || path != "/lights/all/effects/pulse" {
+			t.Errorf("it should have posted to the pulse effect, got %s %v", path, err)
+		}
+	})
+
+	t.Run("when breathing", func(t *testing.T) {
+		_, err := filament.BreatheEffect(client, "all", map[string]interface{}{"color": "red"})
+		if err != nil || path != "/lights/all/effects/breathe" {
+			t.Errorf("it should have posted to the breathe effect, got %s %v", path, err)
+		}
+	})
+}
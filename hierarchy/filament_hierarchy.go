@@ -0,0 +1,222 @@
+package hierarchy
+
+import (
+	"sort"
+
+	"github.com/panicpanicpanic/filament"
+	"github.com/panicpanicpanic/filament/device"
+	"github.com/panicpanicpanic/filament/lifx"
+)
+
+// Location is a LIFX location along with the Groups inside it
+type Location struct {
+	device.Location
+	Groups []*Group
+}
+
+// Group is a LIFX group along with the Devices inside it
+type Group struct {
+	device.Group
+	Location device.Location
+	Devices  []device.Device
+}
+
+// Build arranges the result of filament.GetLights into Locations, each holding its Groups and Devices.
+// Locations and Groups are sorted by name so the hierarchy is stable between calls.
+func Build(devices []device.Device) []*Location {
+	var locations []*Location
+	locationsByID := make(map[string]*Location)
+	groupsByID := make(map[string]*Group)
+
+	for _, d := range devices {
+		location, ok := locationsByID[d.Location.ID]
+		if !ok {
+			location = &Location{Location: d.Location}
+			locationsByID[d.Location.ID] = location
+			locations = append(locations, location)
+		}
+
+		// Group IDs are unique across the account, but lights without a group share an empty ID, so
+		// the location keeps those apart
+		key := d.Location.ID + "/" + d.Group.ID
+		group, ok := groupsByID[key]
+		if !ok {
+			group = &Group{Group: d.Group, Location: d.Location}
+			groupsByID[key] = group
+			location.Groups = append(location.Groups, group)
+		}
+
+		group.Devices = append(group.Devices, d)
+	}
+
+	sort.Slice(locations, func(i, j int) bool { return locations[i].Name < locations[j].Name })
+	for _, location := range locations {
+		groups := location.Groups
+		sort.Slice(groups, func(i, j int) bool { return groups[i].Name < groups[j].Name })
+	}
+
+	return locations
+}
+
+// Devices returns every Device in the Location
+func (l *Location) Devices() []device.Device {
+	var devices []device.Device
+	for _, group := range l.Groups {
+		devices = append(devices, group.Devices...)
+	}
+	return devices
+}
+
+// Selector returns the LIFX selector for every light in the Location
+func (l *Location) Selector() string {
+	return "location_id:" + l.ID
+}
+
+// AnyOn returns true if any Device in the Location is powered on
+func (l *Location) AnyOn() bool {
+	return anyOn(l.Devices())
+}
+
+// AllConnected returns true if every Device in the Location is connected
+func (l *Location) AllConnected() bool {
+	return allConnected(l.Devices())
+}
+
+// AverageBrightness returns the mean brightness of the Devices in the Location
+func (l *Location) AverageBrightness() float64 {
+	return averageBrightness(l.Devices())
+}
+
+// Capabilities returns every capability supported by at least one Device in the Location
+func (l *Location) Capabilities() device.Capabilities {
+	return capabilities(l.Devices())
+}
+
+// SetState sets the state of every light in the Location
+func (l *Location) SetState(client *lifx.Client, payload interface{}) (lifx.Response, error) {
+	return filament.SetState(client, l.Selector(), payload)
+}
+
+// TogglePower turns the Location's lights off if any of them are on, or on if they are all off
+func (l *Location) TogglePower(client *lifx.Client) (lifx.Response, error) {
+	return filament.TogglePower(client, l.Selector())
+}
+
+// StateDelta changes the state of every light in the Location by the amount specified
+func (l *Location) StateDelta(client *lifx.Client, payload interface{}) (lifx.Response, error) {
+	return filament.StateDelta(client, l.Selector(), payload)
+}
+
+// PulseEffect performs a pulse effect on every light in the Location
+func (l *Location) PulseEffect(client *lifx.Client, payload interface{}) (lifx.Response, error) {
+	return filament.PulseEffect(client, l.Selector(), payload)
+}
+
+// BreatheEffect performs a breathe effect on every light in the Location
+func (l *Location) BreatheEffect(client *lifx.Client, payload interface{}) (lifx.Response, error) {
+	return filament.BreatheEffect(client, l.Selector(), payload)
+}
+
+// Selector returns the LIFX selector for every light in the Group
+func (g *Group) Selector() string {
+	return "group_id:" + g.ID
+}
+
+// AnyOn returns true if any Device in the Group is powered on
+func (g *Group) AnyOn() bool {
+	return anyOn(g.Devices)
+}
+
+// AllConnected returns true if every Device in the Group is connected
+func (g *Group) AllConnected() bool {
+	return allConnected(g.Devices)
+}
+
+// AverageBrightness returns the mean brightness of the Devices in the Group
+func (g *Group) AverageBrightness() float64 {
+	return averageBrightness(g.Devices)
+}
+
+// Capabilities returns every capability supported by at least one Device in the Group
+func (g *Group) Capabilities() device.Capabilities {
+	return capabilities(g.Devices)
+}
+
+// SetState sets the state of every light in the Group
+func (g *Group) SetState(client *lifx.Client, payload interface{}) (lifx.Response, error) {
+	return filament.SetState(client, g.Selector(), payload)
+}
+
+// TogglePower turns the Group's lights off if any of them are on, or on if they are all off
+func (g *Group) TogglePower(client *lifx.Client) (lifx.Response, error) {
+	return filament.TogglePower(client, g.Selector())
+}
+
+// StateDelta changes the state of every light in the Group by the amount specified
+func (g *Group) StateDelta(client *lifx.Client, payload interface{}) (lifx.Response, error) {
+	return filament.StateDelta(client, g.Selector(), payload)
+}
+
+// PulseEffect performs a pulse effect on every light in the Group
+func (g *Group) PulseEffect(client *lifx.Client, payload interface{}) (lifx.Response, error) {
+	return filament.PulseEffect(client, g.Selector(), payload)
+}
+
+// BreatheEffect performs a breathe effect on every light in the Group
+func (g *Group) BreatheEffect(client *lifx.Client, payload interface{}) (lifx.Response, error) {
+	return filament.BreatheEffect(client, g.Selector(), payload)
+}
+
+func anyOn(devices []device.Device) bool {
+	for _, d := range devices {
+		if d.Power == "on" {
+			return true
+		}
+	}
+	return false
+}
+
+func allConnected(devices []device.Device) bool {
+	for _, d := range devices {
+		if !d.Connected {
+			return false
+		}
+	}
+	return len(devices) > 0
+}
+
+func averageBrightness(devices []device.Device) float64 {
+	var total float64
+
+	if len(devices) == 0 {
+		return 0
+	}
+
+	for _, d := range devices {
+		total += d.Brightness
+	}
+	return total / float64(len(devices))
+}
+
+// capabilities ORs together every Device's capabilities and widens the kelvin range to cover them all
+func capabilities(devices []device.Device) device.Capabilities {
+	var union device.Capabilities
+
+	for i, d := range devices {
+		c := d.Product.Capabilities
+		union.HasColor = union.HasColor || c.HasColor
+		union.HasVariableColorTemp = union.HasVariableColorTemp || c.HasVariableColorTemp
+		union.HasIR = union.HasIR || c.HasIR
+		union.HasChain = union.HasChain || c.HasChain
+		union.HasMultizone = union.HasMultizone || c.HasMultizone
+
+		if i == 0 || c.MinKelvin < union.MinKelvin {
+			union.MinKelvin = c.MinKelvin
+		}
+		if c.MaxKelvin > union.MaxKelvin {
+			union.MaxKelvin = c.MaxKelvin
+		}
+	}
+
+	return union
+}
@@ -0,0 +1,152 @@
+package hierarchy_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/panicpanicpanic/filament/device"
+	"github.com/panicpanicpanic/filament/hierarchy"
+	"github.com/panicpanicpanic/filament/lifx"
+)
+
+func TestBuild(t *testing.T) {
+	home := device.Location{ID: "1", Name: "Home"}
+	office := device.Location{ID: "2", Name: "Office"}
+
+	devices := []device.Device{
+		{Label: "Desk", Power: "on", Connected: true, Brightness: 1, Location: office, Group: device.Group{ID: "a", Name: "Study"},
+			Product: device.Product{Capabilities: device.Capabilities{HasColor: true, MinKelvin: 2500, MaxKelvin: 9000}}},
+		{Label: "Lamp", Power: "off", Connected: true, Brightness: 0.5, Location: home, Group: device.Group{ID: "b", Name: "Living Room"},
+			Product: device.Product{Capabilities: device.Capabilities{MinKelvin: 2700, MaxKelvin: 6500}}},
+		{Label: "Strip", Power: "off", Connected: false, Brightness: 0, Location: home, Group: device.Group{ID: "b", Name: "Living Room"},
+			Product: device.Product{Capabilities: device.Capabilities{HasMultizone: true, MinKelvin: 1500, MaxKelvin: 9000}}},
+	}
+
+	locations := hierarchy.Build(devices)
+
+	t.Run("when devices share a location and group", func(t *testing.T) {
+		if len(locations) != 2 || locations[0].Name != "Home" {
+			t.Fatalf("it should have built 2 locations sorted by name, got %+v", locations)
+		}
+		if len(locations[0].Groups) != 1 || len(locations[0].Groups[0].Devices) != 2 {
+			t.Errorf("it should have grouped both Home devices together, got %+v", locations[0].Groups)
+		}
+		if locations[0].Groups[0].Selector() != "group_id:b" || locations[0].Selector() != "location_id:1" {
+			t.Errorf("it should have built group_id and location_id selectors")
+		}
+	})
+
+	t.Run("when aggregating a location", func(t *testing.T) {
+		home := locations[0]
+		if home.AnyOn() {
+			t.Errorf("it should not report any lights on at Home")
+		}
+		if home.AllConnected() {
+			t.Errorf("it should not report every Home light connected")
+		}
+		if home.AverageBrightness() != 0.25 {
+			t.Errorf("it should have averaged brightness to 0.25, got %v", home.AverageBrightness())
+		}
+
+		capabilities := home.Capabilities()
+		if !capabilities.HasMultizone || capabilities.HasColor || capabilities.MinKelvin != 1500 || capabilities.MaxKelvin != 9000 {
+			t.Errorf("it should have merged capabilities, got %+v", capabilities)
+		}
+	})
+}
+
+func TestOperations(t *testing.T) {
+	var requests []string
+	var payloads []map[string]interface{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&payload)
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		payloads = append(payloads, payload)
+
+		w.WriteHeader(http.StatusMultiStatus)
+		w.Write([]byte(`{"results": [{"id": "d073d5", "status": "ok"}]}`))
+	}))
+	defer server.Close()
+
+	client := &lifx.Client{AccessToken: "someRandomToken", BaseURL: server.URL}
+	home := device.Location{ID: "1", Name: "Home"}
+	locations := hierarchy.Build([]device.Device{
+		{ID: "d073d5", Power: "on", Connected: true, Brightness: 0.5, Location: home, Group: device.Group{ID: "b", Name: "Living Room"}},
+	})
+	location, group := locations[0], locations[0].Groups[0]
+
+	t.Run("when operating on a location", func(t *testing.T) {
+		requests, payloads = nil, nil
+
+		location.SetState(client, map[string]interface{}{"power": "off"})
+		location.TogglePower(client)
+		location.StateDelta(client, map[string]interface{}{"brightness": 0.1})
+		location.PulseEffect(client, map[string]interface{}{"color": "red"})
+		location.BreatheEffect(client, map[string]interface{}{"color": "red"})
+
+		expected := []string{
+			"PUT /lights/location_id:1/state",
+			"POST /lights/location_id:1/toggle",
+			"POST /lights/location_id:1/state/delta",
+			"POST /lights/location_id:1/effects/pulse",
+			"POST /lights/location_id:1/effects/breathe",
+		}
+		if len(requests) != len(expected) {
+			t.Fatalf("it should have made %d calls, got %v", len(expected), requests)
+		}
+		for i := range expected {
+			if requests[i] != expected[i] {
+				t.Errorf("it should have called %s, got %s", expected[i], requests[i])
+			}
+		}
+		if payloads[0]["power"] != "off" {
+			t.Errorf("it should have sent the payload, got %v", payloads[0])
+		}
+	})
+
+	t.Run("when operating on a group", func(t *testing.T) {
+		requests, payloads = nil, nil
+
+		group.SetState(client, map[string]interface{}{"power": "off"})
+		group.TogglePower(client)
+		group.StateDelta(client, map[string]interface{}{"brightness": 0.1})
+		group.PulseEffect(client, map[string]interface{}{"color": "red"})
+		group.BreatheEffect(client, map[string]interface{}{"color": "red"})
+
+		expected := []string{
+			"PUT /lights/group_id:b/state",
+			"POST /lights/group_id:b/toggle",
+			"POST /lights/group_id:b/state/delta",
+			"POST /lights/group_id:b/effects/pulse",
+			"POST /lights/group_id:b/effects/breathe",
+		}
+		if len(requests) != len(expected) {
+			t.Fatalf("it should have made %d calls, got %v", len(expected), requests)
+		}
+		for i := range expected {
+			if requests[i] != expected[i] {
+				t.Errorf("it should have called %s, got %s", expected[i], requests[i])
+			}
+		}
+	})
+
+	t.Run("when aggregating a group", func(t *testing.T) {
+		if !group.AnyOn() || !group.AllConnected() || group.AverageBrightness() != 0.5 {
+			t.Errorf("it should have aggregated the group's only light, got %+v", group)
+		}
+	})
+
+	t.Run("when lights without a group are in different locations", func(t *testing.T) {
+		locations := hierarchy.Build([]device.Device{
+			{ID: "1", Location: home},
+			{ID: "2", Location: device.Location{ID: "2", Name: "Office"}},
+		})
+		if len(locations) != 2 || len(locations[0].Groups) != 1 || len(locations[1].Groups) != 1 {
+			t.Errorf("it should have kept them in separate groups, got %+v", locations)
+		}
+	})
+}
@@ -0,0 +1,179 @@
+package filament
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/panicpanicpanic/filament/device"
+	"github.com/panicpanicpanic/filament/lifx"
+)
+
+// civilDuskZenith is the sun's zenith angle, in degrees, at civil dusk
+const civilDuskZenith = 96.0
+
+// GetInfrared returns the infrared capable lights within the given selector, along with their infrared levels
+func GetInfrared(client *lifx.Client, selector string) ([]device.Device, error) {
+	devices, err := GetLights(client, selector)
+	if err != nil {
+		return nil, err
+	}
+
+	return infraredCapable(devices), nil
+}
+
+// SetInfrared sets the infrared level of every infrared capable light within the given selector.
+// Lights without infrared are left alone, and an error is returned if the selector has none.
+func SetInfrared(client *lifx.Client, selector string, level device.Infrared) (lifx.Response, error) {
+	var ids []string
+
+	err := level.Validate()
+	if err != nil {
+		return lifx.Response{}, err
+	}
+
+	devices, err := GetInfrared(client, selector)
+	if err != nil {
+		return lifx.Response{}, err
+	}
+
+	for _, d := range devices {
+		ids = append(ids, "id:"+d.ID)
+	}
+
+	if len(ids) == 0 {
+		return lifx.Response{}, fmt.Errorf("no infrared capable lights match %q", selector)
+	}
+
+	return SetState(client, strings.Join(ids, ","), map[string]interface{}{
+		"infrared": float64(level),
+	})
+}
+
+// InfraredSchedule turns on infrared at dusk each day for the lights within Selector
+type InfraredSchedule struct {
+	Selector  string
+	Level     device.Infrared
+	Latitude  float64
+	Longitude float64
+
+	// Offset shifts the schedule relative to civil dusk, e.g. -15 * time.Minute to start early
+	Offset time.Duration
+
+	// OnError, if set, is called when setting infrared fails. The schedule carries on to the next dusk.
+	OnError func(error)
+}
+
+// Next returns the first time after now that the schedule should fire. Near the poles, days on
+// which the sun never sets are skipped.
+func (s InfraredSchedule) Next(now time.Time) time.Time {
+	for day := 0; day < 366; day++ {
+		date := now.AddDate(0, 0, day)
+
+		dusk, ok := Dusk(date, s.Latitude, s.Longitude)
+		if !ok {
+			continue
+		}
+
+		dusk = dusk.Add(s.Offset)
+		if dusk.After(now) {
+			return dusk
+		}
+	}
+
+	return time.Time{}
+}
+
+// Run sets infrared at every dusk until ctx is done. A failed SetInfrared is passed to OnError rather
+// than stopping the schedule, so one LIFX outage doesn't turn night vision off for good.
+func (s InfraredSchedule) Run(ctx context.Context, client *lifx.Client) error {
+	err := s.Level.Validate()
+	if err != nil {
+		return err
+	}
+
+	scheduled := *client
+	scheduled.Context = ctx
+
+	for {
+		next := s.Next(time.Now())
+		if next.IsZero() {
+			return fmt.Errorf("the sun never sets at %g, %g", s.Latitude, s.Longitude)
+		}
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+
+		_, err = SetInfrared(&scheduled, s.Selector, s.Level)
+		if err != nil && ctx.Err() == nil && s.OnError != nil {
+			s.OnError(err)
+		}
+	}
+}
+
+// Dusk returns the time of civil dusk in UTC on the given date at the given coordinates. It returns
+// false if the sun doesn't get low enough for dusk on that date.
+func Dusk(date time.Time, latitude, longitude float64) (time.Time, bool) {
+	rad := math.Pi / 180
+	date = date.UTC()
+
+	// Approximate time of sunset, as a fraction of the day of the year
+	lngHour := longitude / 15
+	t := float64(date.YearDay()) + (18-lngHour)/24
+
+	// Sun's mean anomaly and true longitude
+	m := 0.9856*t - 3.289
+	l := normalize(m+1.916*math.Sin(m*rad)+0.020*math.Sin(2*m*rad)+282.634, 360)
+
+	// Sun's right ascension, in the same quadrant as its longitude, in hours
+	ra := normalize(math.Atan(0.91764*math.Tan(l*rad))/rad, 360)
+	ra += math.Floor(l/90)*90 - math.Floor(ra/90)*90
+	ra /= 15
+
+	// Sun's declination and local hour angle
+	sinDec := 0.39782 * math.Sin(l*rad)
+	cosDec := math.Cos(math.Asin(sinDec))
+	cosH := (math.Cos(civilDuskZenith*rad) - sinDec*math.Sin(latitude*rad)) / (cosDec * math.Cos(latitude*rad))
+	if cosH < -1 || cosH > 1 {
+		return time.Time{}, false
+	}
+	h := math.Acos(cosH) / rad / 15
+
+	// Keep the result on the same local day, since west of Greenwich dusk often falls after midnight UTC
+	ut := normalize(h+ra-0.06571*t-6.622-lngHour, 24)
+	if ut+lngHour < 0 {
+		ut += 24
+	} else if ut+lngHour >= 24 {
+		ut -= 24
+	}
+	midnight := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
+
+	return midnight.Add(time.Duration(ut * float64(time.Hour))), true
+}
+
+func infraredCapable(devices []device.Device) []device.Device {
+	var capable []device.Device
+
+	for _, d := range devices {
+		if d.Product.Capabilities.HasIR {
+			capable = append(capable, d)
+		}
+	}
+
+	return capable
+}
+
+func normalize(value, max float64) float64 {
+	value = math.Mod(value, max)
+	if value < 0 {
+		value += max
+	}
+	return value
+}
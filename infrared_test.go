@@ -0,0 +1,139 @@
+package filament_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/panicpanicpanic/filament"
+	"github.com/panicpanicpanic/filament/device"
+	"github.com/panicpanicpanic/filament/lifx"
+)
+
+func TestDusk(t *testing.T) {
+	// Expected times are published civil twilight ends, in UTC
+	for _, c := range []struct {
+		place     string
+		date      time.Time
+		latitude  float64
+		longitude float64
+		expected  time.Time
+	}{
+		{"London at midsummer", time.Date(2024, 6, 21, 12, 0, 0, 0, time.UTC), 51.5074, -0.1278, time.Date(2024, 6, 21, 21, 4, 0, 0, time.UTC)},
+		{"New York in winter", time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC), 40.7128, -74.0060, time.Date(2024, 1, 1, 22, 11, 0, 0, time.UTC)},
+		{"Sydney in summer", time.Date(2024, 12, 21, 0, 0, 0, 0, time.UTC), -33.8688, 151.2093, time.Date(2024, 12, 21, 9, 34, 0, 0, time.UTC)},
+	} {
+		t.Run("when the date is "+c.place, func(t *testing.T) {
+			dusk, ok := filament.Dusk(c.date, c.latitude, c.longitude)
+			if !ok {
+				t.Fatalf("it should have found dusk")
+			}
+
+			difference := dusk.Sub(c.expected)
+			if difference < -10*time.Minute || difference > 10*time.Minute {
+				t.Errorf("it should have been within 10 minutes of %s, got %s", c.expected, dusk)
+			}
+		})
+	}
+
+	t.Run("when it is midsummer inside the Arctic circle", func(t *testing.T) {
+		if _, ok := filament.Dusk(time.Date(2024, 6, 21, 12, 0, 0, 0, time.UTC), 69.6492, 18.9553); ok {
+			t.Errorf("it should have found no dusk during the midnight sun")
+		}
+	})
+
+	t.Run("when it is midwinter near the pole", func(t *testing.T) {
+		if _, ok := filament.Dusk(time.Date(2024, 12, 21, 12, 0, 0, 0, time.UTC), 78.22, 15.65); ok {
+			t.Errorf("it should have found no dusk when the sun never gets near the horizon")
+		}
+	})
+}
+
+func TestInfraredScheduleNext(t *testing.T) {
+	t.Run("when dusk is later today", func(t *testing.T) {
+		schedule := filament.InfraredSchedule{Latitude: 51.5074, Longitude: -0.1278, Offset: -15 * time.Minute}
+		now := time.Date(2024, 6, 21, 12, 0, 0, 0, time.UTC)
+
+		next := schedule.Next(now)
+		dusk, _ := filament.Dusk(now, 51.5074, -0.1278)
+		if !next.Equal(dusk.Add(-15 * time.Minute)) {
+			t.Errorf("it should have fired 15 minutes before today's dusk, got %s", next)
+		}
+	})
+
+	t.Run("when today's dusk has passed", func(t *testing.T) {
+		schedule := filament.InfraredSchedule{Latitude: 51.5074, Longitude: -0.1278}
+		now := time.Date(2024, 6, 21, 23, 0, 0, 0, time.UTC)
+
+		if next := schedule.Next(now); next.Day() != 22 {
+			t.Errorf("it should have fired tomorrow, got %s", next)
+		}
+	})
+
+	t.Run("when the midnight sun lasts for weeks", func(t *testing.T) {
+		schedule := filament.InfraredSchedule{Latitude: 69.6492, Longitude: 18.9553}
+
+		next := schedule.Next(time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC))
+		if next.IsZero() || next.Before(time.Date(2024, 7, 20, 0, 0, 0, 0, time.UTC)) {
+			t.Errorf("it should have skipped to the first dusk after the midnight sun, got %s", next)
+		}
+	})
+}
+
+func TestSetInfrared(t *testing.T) {
+	var path string
+	var payload map[string]interface{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" && r.URL.Path == "/lights/id:d2" {
+			w.Write([]byte(`[{"id": "d2", "product": {"capabilities": {"has_ir": false}}}]`))
+			return
+		}
+		if r.Method == "GET" {
+			w.Write([]byte(`[
+				{"id": "d1", "infrared": "0.5", "product": {"capabilities": {"has_ir": true}}},
+				{"id": "d2", "product": {"capabilities": {"has_ir": false}}}
+			]`))
+			return
+		}
+
+		path = r.URL.Path
+		json.NewDecoder(r.Body).Decode(&payload)
+		w.WriteHeader(http.StatusMultiStatus)
+		w.Write([]byte(`{"results": [{"id": "d1", "status": "ok"}]}`))
+	}))
+	defer server.Close()
+
+	client := &lifx.Client{AccessToken: "someRandomToken", BaseURL: server.URL}
+
+	t.Run("when some lights have infrared", func(t *testing.T) {
+		_, err := filament.SetInfrared(client, "all", 0.8)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if path != "/lights/id:d1/state" || payload["infrared"] != 0.8 {
+			t.Errorf("it should have only set the infrared light, got %s %v", path, payload)
+		}
+	})
+
+	t.Run("when listing infrared lights", func(t *testing.T) {
+		devices, err := filament.GetInfrared(client, "all")
+		if err != nil || len(devices) != 1 || devices[0].Infrared != 0.5 {
+			t.Errorf("it should have returned the infrared light and its level, got %+v %v", devices, err)
+		}
+	})
+
+	t.Run("when the level is out of range", func(t *testing.T) {
+		if _, err := filament.SetInfrared(client, "all", device.Infrared(1.5)); err == nil {
+			t.Errorf("it should have returned an error")
+		}
+	})
+
+	t.Run("when no light has infrared", func(t *testing.T) {
+		if _, err := filament.SetInfrared(client, "id:d2", 0.5); err == nil {
+			t.Errorf("it should have returned an error")
+		}
+	})
+}
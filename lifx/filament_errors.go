@@ -0,0 +1,48 @@
+package lifx
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+var (
+	// ErrUnauthorized means LIFX didn't recognise the AccessToken, or none was given
+	ErrUnauthorized = errors.New("lifx: invalid or missing access token")
+
+	// ErrForbidden means the AccessToken is valid but isn't allowed to make the request
+	ErrForbidden = errors.New("lifx: access token lacks permission")
+
+	// ErrRateLimited means LIFX rejected the request for exceeding the rate limit
+	ErrRateLimited = errors.New("lifx: rate limit exceeded")
+)
+
+// StatusError is returned when the LIFX HTTP API responds with an error status code. Use errors.Is
+// with ErrUnauthorized, ErrForbidden or ErrRateLimited to tell the common failures apart.
+type StatusError struct {
+	StatusCode int
+	Body       string
+}
+
+// Error returns the status code and the body LIFX sent with it
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("Uh oh! You've received a %d status code. Error: %s", e.StatusCode, e.Body)
+}
+
+// Is matches the StatusError against ErrUnauthorized, ErrForbidden and ErrRateLimited
+func (e *StatusError) Is(target error) bool {
+	switch target {
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized
+	case ErrForbidden:
+		return e.StatusCode == http.StatusForbidden
+	case ErrRateLimited:
+		return e.StatusCode == http.StatusTooManyRequests
+	}
+	return false
+}
+
+// IsAuthError returns true if err means the AccessToken was missing, invalid, or not allowed to make a request
+func IsAuthError(err error) bool {
+	return errors.Is(err, ErrUnauthorized) || errors.Is(err, ErrForbidden)
+}
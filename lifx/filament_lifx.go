@@ -1,5 +1,11 @@
 package lifx
 
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
 const (
 	// LIFXAPIURL is the URL for the latest LIFX HTTP API
 	LIFXAPIURL = "https://api.lifx.com/v1"
@@ -9,6 +15,38 @@ const (
 type Client struct {
 	AccessToken string
 	Endpoint    string
+
+	// BaseURL, if set, is used instead of LIFXAPIURL, e.g. to reach LIFX through filament-proxy
+	BaseURL string
+
+	// Context, if set, is attached to every request made with the Client so callers can cancel them
+	Context context.Context
+
+	// Limiter, if set, is waited on before every request made with the Client
+	Limiter *RateLimiter
+
+	// HTTPClient, if set, sends every request, e.g. to add instrumentation with a custom Transport
+	HTTPClient *http.Client
+
+	// Retries is how many times a request that was rate limited is retried. GETs and PUTs are also
+	// retried after a LIFX server error, but POSTs aren't, since LIFX may already have applied them.
+	Retries int
+
+	// Observers are notified before and after every call made with the Client
+	Observers []Observer
+
+	// Strict makes SetState and SetStates check payloads against the targeted devices' capabilities,
+	// returning an error instead of sending anything LIFX would have to ignore or clamp. The account's
+	// lights are fetched once and reused for 30s, so checking doesn't double the requests made.
+	Strict bool
+}
+
+// URL returns the base URL the Client's Endpoints are built from
+func (c *Client) URL() string {
+	if c.BaseURL == "" {
+		return LIFXAPIURL
+	}
+	return strings.TrimSuffix(c.BaseURL, "/")
 }
 
 // Response is a generic slice of results from LIFX API
@@ -0,0 +1,72 @@
+package lifx
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultRateLimit is the number of requests LIFX allows per DefaultRatePeriod
+	DefaultRateLimit = 120
+
+	// DefaultRatePeriod is the window DefaultRateLimit applies to
+	DefaultRatePeriod = time.Minute
+)
+
+// RateLimiter spaces requests evenly so a Client stays within the LIFX HTTP API rate limit.
+// A single RateLimiter can be shared by any number of Clients using the same AccessToken.
+type RateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+// NewRateLimiter returns a RateLimiter allowing the given number of requests per period
+func NewRateLimiter(requests int, period time.Duration) *RateLimiter {
+	if requests <= 0 {
+		requests = DefaultRateLimit
+	}
+	if period <= 0 {
+		period = DefaultRatePeriod
+	}
+
+	return &RateLimiter{interval: period / time.Duration(requests)}
+}
+
+// Interval returns the minimum time between two requests allowed by the RateLimiter
+func (r *RateLimiter) Interval() time.Duration {
+	return r.interval
+}
+
+// Wait blocks until the next request is allowed, or returns an error if ctx is done first
+func (r *RateLimiter) Wait(ctx context.Context) error {
+	// A caller that has already given up shouldn't use up a slot others are waiting for
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	now := time.Now()
+	slot := r.next
+	if slot.Before(now) {
+		slot = now
+	}
+	r.next = slot.Add(r.interval)
+	r.mu.Unlock()
+
+	delay := slot.Sub(now)
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,44 @@
+package lifx_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/panicpanicpanic/filament/lifx"
+)
+
+func TestRateLimiter(t *testing.T) {
+	t.Run("when requests are made back to back", func(t *testing.T) {
+		limiter := lifx.NewRateLimiter(100, time.Second)
+
+		start := time.Now()
+		for i := 0; i < 3; i++ {
+			limiter.Wait(context.Background())
+		}
+		if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+			t.Errorf("it should have spaced the requests 10ms apart, took %s", elapsed)
+		}
+	})
+
+	t.Run("when ctx is already done", func(t *testing.T) {
+		limiter := lifx.NewRateLimiter(1, time.Hour)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		if err := limiter.Wait(ctx); err != context.Canceled {
+			t.Errorf("it should have returned the ctx error, got %v", err)
+		}
+
+		done := make(chan error, 1)
+		go func() { done <- limiter.Wait(context.Background()) }()
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Errorf("it should have let the next request through, got %s", err)
+			}
+		case <-time.After(time.Second):
+			t.Errorf("it should not have used up the slot for a cancelled caller")
+		}
+	})
+}
@@ -0,0 +1,86 @@
+package lifx
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Call describes a single call made through the service package, including any retries
+type Call struct {
+	Method   string
+	Endpoint string
+	Selector string
+
+	// Request is the JSON payload sent, if any
+	Request []byte
+
+	// Header holds a copy of the request headers, with the AccessToken in the Authorization header redacted
+	Header http.Header
+
+	// The remaining fields are filled in once the call finishes
+	StatusCode     int
+	ResponseHeader http.Header
+	Attempts       int
+	Duration       time.Duration
+	Response       []byte
+	Err            error
+}
+
+// Observer is notified before and after every call made with a Client, e.g. to trace or log it.
+// The context returned by Begin is used for the call, and is passed back to End.
+type Observer interface {
+	Begin(ctx context.Context, call *Call) context.Context
+	End(ctx context.Context, call *Call)
+}
+
+type attemptKey struct{}
+
+// WithAttempt returns a copy of ctx recording which attempt (starting at 0) a request is
+func WithAttempt(ctx context.Context, attempt int) context.Context {
+	return context.WithValue(ctx, attemptKey{}, attempt)
+}
+
+// Attempt returns which attempt the request carrying ctx is, where 0 is the first try and
+// anything higher is a retry
+func Attempt(ctx context.Context) int {
+	attempt, _ := ctx.Value(attemptKey{}).(int)
+	return attempt
+}
+
+// EndpointTemplate splits a request path such as "/v1/lights/label:Desk/state" into the endpoint
+// it calls ("/lights/:selector/state") and the selector it targets ("label:Desk"). This keeps
+// selectors out of metric labels and span names.
+func EndpointTemplate(path string) (string, string) {
+	for _, prefix := range []string{"/lights/", "/scenes/"} {
+		i := strings.Index(path, prefix)
+		if i < 0 {
+			continue
+		}
+
+		rest := path[i+len(prefix):]
+		if rest == "states" {
+			return "/lights/states", ""
+		}
+
+		selector := rest
+		suffix := ""
+		if j := strings.Index(rest, "/"); j >= 0 {
+			selector, suffix = rest[:j], rest[j:]
+		}
+
+		if prefix == "/scenes/" {
+			return "/scenes/:scene_id" + suffix, selector
+		}
+		return "/lights/:selector" + suffix, selector
+	}
+
+	for _, endpoint := range []string{"/scenes", "/color"} {
+		if strings.HasSuffix(path, endpoint) {
+			return endpoint, ""
+		}
+	}
+
+	return path, ""
+}
@@ -0,0 +1,227 @@
+package logging
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/panicpanicpanic/filament/lifx"
+)
+
+const (
+	// DefaultMaxBodyLength is how many bytes of each body are logged when Options.MaxBodyLength is zero
+	DefaultMaxBodyLength = 512
+
+	redacted = "[REDACTED]"
+)
+
+// sensitiveHeaders are never logged in full
+var sensitiveHeaders = map[string]bool{
+	"Authorization": true,
+	"Cookie":        true,
+	"Set-Cookie":    true,
+	"X-Api-Key":     true,
+}
+
+// sensitiveFields are the substrings that mark a JSON field as holding a secret
+var sensitiveFields = []string{"token", "secret", "password", "authorization", "api_key", "apikey"}
+
+// Options controls what an Observer logs
+type Options struct {
+	// Level is used for calls that succeed, and defaults to slog.LevelDebug. Pass a *slog.LevelVar
+	// to change it while the Client is in use.
+	Level slog.Leveler
+
+	// ErrorLevel is used for calls that fail, and defaults to slog.LevelWarn
+	ErrorLevel slog.Leveler
+
+	// LogHeaders adds the request headers, with credentials redacted
+	LogHeaders bool
+
+	// LogBodies adds the request and response bodies, with secrets redacted and truncated to MaxBodyLength
+	LogBodies     bool
+	MaxBodyLength int
+}
+
+// Observer is a lifx.Observer that logs a summary of every call made with a Client
+type Observer struct {
+	logger  *slog.Logger
+	options Options
+
+	// client's AccessToken is scrubbed from anything logged, in case LIFX echoes it back. It is read
+	// for every call, so a rotated token is scrubbed too.
+	client *lifx.Client
+}
+
+// NewObserver returns an Observer logging to logger, or slog.Default() if logger is nil
+func NewObserver(logger *slog.Logger, options Options) *Observer {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	if options.Level == nil {
+		options.Level = slog.LevelDebug
+	}
+	if options.ErrorLevel == nil {
+		options.ErrorLevel = slog.LevelWarn
+	}
+	if options.MaxBodyLength <= 0 {
+		options.MaxBodyLength = DefaultMaxBodyLength
+	}
+
+	return &Observer{logger: logger, options: options}
+}
+
+// Instrument adds an Observer logging to logger to the client. Unlike an Observer from NewObserver,
+// it also scrubs the client's AccessToken from the error messages and bodies it logs.
+func Instrument(client *lifx.Client, logger *slog.Logger, options Options) {
+	observer := NewObserver(logger, options)
+	observer.client = client
+
+	client.Observers = append(client.Observers, observer)
+}
+
+// ClientValue describes a Client for log/slog without its AccessToken, e.g.
+// logger.Info("starting", "client", logging.ClientValue(client))
+func ClientValue(client *lifx.Client) slog.Value {
+	return slog.GroupValue(
+		slog.String("access_token", redacted),
+		slog.String("endpoint", client.Endpoint),
+		slog.String("base_url", client.URL()),
+	)
+}
+
+// Begin implements lifx.Observer. Calls are only logged once they finish.
+func (o *Observer) Begin(ctx context.Context, call *lifx.Call) context.Context {
+	return ctx
+}
+
+// End logs the call's method, endpoint, selector, status, attempts and duration
+func (o *Observer) End(ctx context.Context, call *lifx.Call) {
+	level := o.options.Level.Level()
+	if call.Err != nil {
+		level = o.options.ErrorLevel.Level()
+	}
+
+	if !o.logger.Enabled(ctx, level) {
+		return
+	}
+
+	attrs := []slog.Attr{
+		slog.String("method", call.Method),
+		slog.String("endpoint", call.Endpoint),
+		slog.String("selector", call.Selector),
+		slog.Int("status", call.StatusCode),
+		slog.Int("attempts", call.Attempts),
+		slog.Duration("duration", call.Duration),
+	}
+
+	if call.Err != nil {
+		attrs = append(attrs, slog.String("error", o.redactToken(redactError(call.Err))))
+	}
+
+	if o.options.LogHeaders {
+		attrs = append(attrs, slog.Any("headers", RedactHeader(call.Header)))
+	}
+
+	if o.options.LogBodies {
+		attrs = append(attrs,
+			slog.String("request_body", o.body(call.Request)),
+			slog.String("response_body", o.body(call.Response)),
+		)
+	}
+
+	o.logger.LogAttrs(ctx, level, "lifx call", attrs...)
+}
+
+func (o *Observer) body(body []byte) string {
+	text := o.redactToken(string(RedactJSON(body)))
+
+	if len(text) > o.options.MaxBodyLength {
+		return fmt.Sprintf("%s...(%d more bytes)", text[:o.options.MaxBodyLength], len(text)-o.options.MaxBodyLength)
+	}
+	return text
+}
+
+// RedactHeader returns a copy of header with credentials replaced by "[REDACTED]"
+func RedactHeader(header http.Header) http.Header {
+	clean := make(http.Header, len(header))
+
+	for key, values := range header {
+		if sensitiveHeaders[http.CanonicalHeaderKey(key)] {
+			clean[key] = []string{redacted}
+			continue
+		}
+		clean[key] = values
+	}
+
+	return clean
+}
+
+// RedactJSON replaces the value of any field that looks like it holds a secret. Bodies that aren't
+// JSON are returned unchanged.
+func RedactJSON(body []byte) []byte {
+	var value interface{}
+
+	if len(body) == 0 || json.Unmarshal(body, &value) != nil {
+		return body
+	}
+
+	clean, err := json.Marshal(redactValue(value))
+	if err != nil {
+		return body
+	}
+	return clean
+}
+
+func redactValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, field := range v {
+			if sensitive(key) {
+				v[key] = redacted
+			} else {
+				v[key] = redactValue(field)
+			}
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = redactValue(item)
+		}
+	}
+
+	return value
+}
+
+func sensitive(field string) bool {
+	field = strings.ToLower(field)
+
+	for _, marker := range sensitiveFields {
+		if strings.Contains(field, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+func (o *Observer) redactToken(text string) string {
+	if o.client == nil || o.client.AccessToken == "" {
+		return text
+	}
+	return strings.Replace(text, o.client.AccessToken, redacted, -1)
+}
+
+// redactError returns the error's message, with secret fields redacted from the body of a StatusError
+func redactError(err error) string {
+	var statusErr *lifx.StatusError
+	if !errors.As(err, &statusErr) {
+		return err.Error()
+	}
+
+	clean := *statusErr
+	clean.Body = string(RedactJSON([]byte(clean.Body)))
+	return clean.Error()
+}
@@ -0,0 +1,61 @@
+package logging_test
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/panicpanicpanic/filament"
+	"github.com/panicpanicpanic/filament/lifx"
+	"github.com/panicpanicpanic/filament/logging"
+)
+
+func TestObserver(t *testing.T) {
+	var output bytes.Buffer
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"error": "Invalid token ` + token + `", "access_token": "` + token + `"}`))
+	}))
+	defer server.Close()
+
+	logger := slog.New(slog.NewJSONHandler(&output, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	client := lifx.Client{AccessToken: "secretToken123", BaseURL: server.URL}
+	logging.Instrument(&client, logger, logging.Options{LogHeaders: true, LogBodies: true, MaxBodyLength: 40})
+
+	_, err := filament.TogglePower(&client, "label:Desk")
+	if err == nil {
+		t.Fatalf("it should have returned the 401 as an error")
+	}
+
+	logged := output.String()
+	if strings.Contains(logged, "secretToken123") {
+		t.Errorf("it should have redacted the token everywhere, got %s", logged)
+	}
+	if !strings.Contains(logged, `"level":"WARN"`) || !strings.Contains(logged, `"endpoint":"/lights/:selector/toggle"`) {
+		t.Errorf("it should have logged a summary of the failed call at warn level, got %s", logged)
+	}
+	if !strings.Contains(logged, `\"access_token\":\"[REDACTED]\"`) {
+		t.Errorf("it should have redacted secret fields in the error's body, got %s", logged)
+	}
+	if !strings.Contains(logged, "more bytes") {
+		t.Errorf("it should have truncated the response body, got %s", logged)
+	}
+
+	output.Reset()
+	client.AccessToken = "rotatedToken456"
+	filament.TogglePower(&client, "label:Desk")
+	if logged := output.String(); strings.Contains(logged, "rotatedToken456") || !strings.Contains(logged, "Invalid token [REDACTED]") {
+		t.Errorf("it should have redacted the rotated token, got %s", logged)
+	}
+
+	output.Reset()
+	logger.Info("client", "client", logging.ClientValue(&client))
+	if strings.Contains(output.String(), "secretToken123") {
+		t.Errorf("it should have kept the token out of a logged Client, got %s", output.String())
+	}
+}
@@ -0,0 +1,83 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/panicpanicpanic/filament"
+	"github.com/panicpanicpanic/filament/lifx"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	deviceLabels = []string{"id", "label", "group", "location", "product"}
+
+	upDesc               = prometheus.NewDesc("lifx_up", "Whether the last GetLights call for device metrics succeeded.", nil, nil)
+	connectedDesc        = prometheus.NewDesc("lifx_device_connected", "Whether the device is connected to the LIFX cloud.", deviceLabels, nil)
+	powerDesc            = prometheus.NewDesc("lifx_device_power", "Whether the device is powered on.", deviceLabels, nil)
+	brightnessDesc       = prometheus.NewDesc("lifx_device_brightness", "Brightness of the device, from 0 to 1.", deviceLabels, nil)
+	hueDesc              = prometheus.NewDesc("lifx_device_hue", "Hue of the device, from 0 to 360.", deviceLabels, nil)
+	saturationDesc       = prometheus.NewDesc("lifx_device_saturation", "Saturation of the device, from 0 to 1.", deviceLabels, nil)
+	kelvinDesc           = prometheus.NewDesc("lifx_device_kelvin", "Color temperature of the device in kelvin.", deviceLabels, nil)
+	secondsSinceSeenDesc = prometheus.NewDesc("lifx_device_seconds_since_seen", "Seconds since LIFX last heard from the device.", deviceLabels, nil)
+)
+
+// DeviceCollector exports a gauge per device for every light within Selector, calling GetLights
+// once per scrape
+type DeviceCollector struct {
+	Client   *lifx.Client
+	Selector string
+
+	// Timeout bounds the GetLights call made for each scrape, defaulting to 10 seconds
+	Timeout time.Duration
+}
+
+// Describe implements prometheus.Collector
+func (c *DeviceCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range []*prometheus.Desc{upDesc, connectedDesc, powerDesc, brightnessDesc, hueDesc, saturationDesc, kelvinDesc, secondsSinceSeenDesc} {
+		ch <- desc
+	}
+}
+
+// Collect implements prometheus.Collector
+func (c *DeviceCollector) Collect(ch chan<- prometheus.Metric) {
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	client := *c.Client
+	client.Context = ctx
+
+	devices, err := filament.GetLights(&client, c.Selector)
+	if err != nil {
+		ch <- prometheus.MustNewConstMetric(upDesc, prometheus.GaugeValue, 0)
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(upDesc, prometheus.GaugeValue, 1)
+
+	for _, d := range devices {
+		labels := []string{d.ID, d.Label, d.Group.Name, d.Location.Name, d.Product.Name}
+		gauge := func(desc *prometheus.Desc, value float64) {
+			ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, value, labels...)
+		}
+
+		gauge(connectedDesc, boolValue(d.Connected))
+		gauge(powerDesc, boolValue(d.Power == "on"))
+		gauge(brightnessDesc, d.Brightness)
+		gauge(hueDesc, d.Color.Hue)
+		gauge(saturationDesc, d.Color.Saturation)
+		gauge(kelvinDesc, d.Color.Kelvin)
+		gauge(secondsSinceSeenDesc, float64(d.SecondsSinceSeen))
+	}
+}
+
+func boolValue(value bool) float64 {
+	if value {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,121 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/panicpanicpanic/filament/lifx"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// ClientMetrics records every request a lifx.Client makes. Register it with a Prometheus registry
+// and install it on a client with Instrument.
+type ClientMetrics struct {
+	requests  *prometheus.CounterVec
+	latency   *prometheus.HistogramVec
+	retries   *prometheus.CounterVec
+	remaining prometheus.Gauge
+}
+
+// NewClientMetrics returns ClientMetrics with all of its metrics at zero
+func NewClientMetrics() *ClientMetrics {
+	return &ClientMetrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "lifx_requests_total",
+			Help: "Requests made to the LIFX HTTP API, by endpoint, method and status code.",
+		}, []string{"endpoint", "method", "status"}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "lifx_request_duration_seconds",
+			Help:    "Time taken by requests to the LIFX HTTP API, by endpoint and method.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"endpoint", "method"}),
+		retries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "lifx_retries_total",
+			Help: "Requests to the LIFX HTTP API that were retries of an earlier attempt, by endpoint.",
+		}, []string{"endpoint"}),
+		remaining: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "lifx_rate_limit_remaining",
+			Help: "Requests LIFX will allow before the current rate limit window resets.",
+		}),
+	}
+}
+
+// Describe implements prometheus.Collector
+func (m *ClientMetrics) Describe(ch chan<- *prometheus.Desc) {
+	m.requests.Describe(ch)
+	m.latency.Describe(ch)
+	m.retries.Describe(ch)
+	m.remaining.Describe(ch)
+}
+
+// Collect implements prometheus.Collector
+func (m *ClientMetrics) Collect(ch chan<- prometheus.Metric) {
+	m.requests.Collect(ch)
+	m.latency.Collect(ch)
+	m.retries.Collect(ch)
+	m.remaining.Collect(ch)
+}
+
+// Instrument makes the client record its requests in m, wrapping any HTTPClient it already has
+func (m *ClientMetrics) Instrument(client *lifx.Client) {
+	instrumented := http.Client{}
+	if client.HTTPClient != nil {
+		instrumented = *client.HTTPClient
+	}
+
+	instrumented.Transport = m.Transport(instrumented.Transport)
+	client.HTTPClient = &instrumented
+}
+
+// Transport wraps next, or http.DefaultTransport if it is nil, so every round trip is recorded in m
+func (m *ClientMetrics) Transport(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	return roundTripper(func(request *http.Request) (*http.Response, error) {
+		endpoint, _ := lifx.EndpointTemplate(request.URL.Path)
+		if lifx.Attempt(request.Context()) > 0 {
+			m.retries.WithLabelValues(endpoint).Inc()
+		}
+
+		start := time.Now()
+		response, err := next.RoundTrip(request)
+		m.latency.WithLabelValues(endpoint, request.Method).Observe(time.Since(start).Seconds())
+
+		status := "error"
+		if err == nil {
+			status = strconv.Itoa(response.StatusCode)
+
+			if remaining, err := strconv.ParseFloat(response.Header.Get("X-RateLimit-Remaining"), 64); err == nil {
+				m.remaining.Set(remaining)
+			}
+		}
+		m.requests.WithLabelValues(endpoint, request.Method, status).Inc()
+
+		return response, err
+	})
+}
+
+type roundTripper func(*http.Request) (*http.Response, error)
+
+func (f roundTripper) RoundTrip(request *http.Request) (*http.Response, error) {
+	return f(request)
+}
+
+// Handler returns an http.Handler serving device gauges for the lights within selector, along with
+// request metrics for the GetLights calls it makes, ready to be mounted at /metrics. It works on a
+// copy of client; use ClientMetrics.Instrument to record the client's own requests.
+func Handler(client *lifx.Client, selector string) http.Handler {
+	instrumented := *client
+
+	clientMetrics := NewClientMetrics()
+	clientMetrics.Instrument(&instrumented)
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(clientMetrics, &DeviceCollector{Client: &instrumented, Selector: selector})
+
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
@@ -0,0 +1,137 @@
+package metrics_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/panicpanicpanic/filament"
+	"github.com/panicpanicpanic/filament/lifx"
+	"github.com/panicpanicpanic/filament/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestClientMetrics(t *testing.T) {
+	var attempts int
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.Header().Set("X-RateLimit-Remaining", "42")
+		if attempts == 1 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte(`[]`))
+	}))
+	defer server.Close()
+
+	clientMetrics := metrics.NewClientMetrics()
+	client := &lifx.Client{AccessToken: "someRandomToken", BaseURL: server.URL, Retries: 1}
+	clientMetrics.Instrument(client)
+
+	t.Run("when a request is retried", func(t *testing.T) {
+		_, err := filament.GetLights(client, "group:Office")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := `
+			# HELP lifx_requests_total Requests made to the LIFX HTTP API, by endpoint, method and status code.
+			# TYPE lifx_requests_total counter
+			lifx_requests_total{endpoint="/lights/:selector",method="GET",status="200"} 1
+			lifx_requests_total{endpoint="/lights/:selector",method="GET",status="429"} 1
+			# HELP lifx_retries_total Requests to the LIFX HTTP API that were retries of an earlier attempt, by endpoint.
+			# TYPE lifx_retries_total counter
+			lifx_retries_total{endpoint="/lights/:selector"} 1
+			# HELP lifx_rate_limit_remaining Requests LIFX will allow before the current rate limit window resets.
+			# TYPE lifx_rate_limit_remaining gauge
+			lifx_rate_limit_remaining 42
+		`
+		err = testutil.CollectAndCompare(clientMetrics, strings.NewReader(expected), "lifx_requests_total", "lifx_retries_total", "lifx_rate_limit_remaining")
+		if err != nil {
+			t.Errorf("it should have recorded both attempts, got %s", err)
+		}
+	})
+}
+
+func TestDeviceCollector(t *testing.T) {
+	failing := false
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte(`[{"id": "d1", "label": "Desk", "connected": true, "power": "on", "brightness": 0.5,
+			"color": {"hue": 120, "saturation": 1, "kelvin": 3500}, "group": {"name": "Office"}}]`))
+	}))
+	defer server.Close()
+
+	collector := &metrics.DeviceCollector{Client: &lifx.Client{AccessToken: "someRandomToken", BaseURL: server.URL}}
+
+	t.Run("when the lights are listed", func(t *testing.T) {
+		expected := `
+			# HELP lifx_up Whether the last GetLights call for device metrics succeeded.
+			# TYPE lifx_up gauge
+			lifx_up 1
+			# HELP lifx_device_brightness Brightness of the device, from 0 to 1.
+			# TYPE lifx_device_brightness gauge
+			lifx_device_brightness{group="Office",id="d1",label="Desk",location="",product=""} 0.5
+			# HELP lifx_device_power Whether the device is powered on.
+			# TYPE lifx_device_power gauge
+			lifx_device_power{group="Office",id="d1",label="Desk",location="",product=""} 1
+		`
+		err := testutil.CollectAndCompare(collector, strings.NewReader(expected), "lifx_up", "lifx_device_brightness", "lifx_device_power")
+		if err != nil {
+			t.Errorf("it should have exported a gauge per device, got %s", err)
+		}
+	})
+
+	t.Run("when GetLights fails", func(t *testing.T) {
+		failing = true
+
+		if count := testutil.CollectAndCount(collector); count != 1 {
+			t.Errorf("it should have only exported lifx_up, got %d metrics", count)
+		}
+	})
+}
+
+func TestHandler(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"id": "d1", "label": "Desk", "power": "on"}]`))
+	}))
+	defer server.Close()
+
+	client := &lifx.Client{AccessToken: "someRandomToken", BaseURL: server.URL}
+	metricsServer := httptest.NewServer(metrics.Handler(client, "all"))
+	defer metricsServer.Close()
+
+	scrape := func() string {
+		response, err := http.Get(metricsServer.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer response.Body.Close()
+
+		body, _ := ioutil.ReadAll(response.Body)
+		return string(body)
+	}
+
+	t.Run("when metrics are scraped", func(t *testing.T) {
+		// Request metrics are collected alongside the GetLights call, so they show up from the second scrape
+		scrape()
+		body := scrape()
+
+		if !strings.Contains(body, "lifx_device_power{") || !strings.Contains(body, "lifx_requests_total{") {
+			t.Errorf("it should have served device and request metrics, got %s", body)
+		}
+	})
+
+	t.Run("when the handler is created", func(t *testing.T) {
+		if client.HTTPClient != nil {
+			t.Errorf("it should not have instrumented the caller's client")
+		}
+	})
+}
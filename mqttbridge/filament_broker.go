@@ -0,0 +1,165 @@
+package mqttbridge
+
+import (
+	"strings"
+	"sync"
+)
+
+// MemoryBroker is an in-process Broker with MQTT topic matching and retained messages, which makes it
+// handy for tests and for wiring a Bridge into the same program. Each subscriber is handed its
+// messages in order on its own goroutine, so a slow subscriber doesn't hold up publishers or the
+// other subscribers.
+type MemoryBroker struct {
+	mu            sync.Mutex
+	retained      map[string][]byte
+	subscriptions []*subscription
+	closed        bool
+}
+
+type subscription struct {
+	filter  string
+	handler func(topic string, payload []byte)
+
+	mu      sync.Mutex
+	queue   []message
+	waiting chan struct{}
+	done    chan struct{}
+}
+
+type message struct {
+	topic   string
+	payload []byte
+}
+
+// NewMemoryBroker returns an empty MemoryBroker
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{retained: make(map[string][]byte)}
+}
+
+// Publish queues the payload for every matching subscriber, keeping it for later subscribers if
+// retained. As in MQTT, a retained empty payload clears the topic's retained message.
+func (m *MemoryBroker) Publish(topic string, payload []byte, retained bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if retained && len(payload) == 0 {
+		delete(m.retained, topic)
+	} else if retained {
+		m.retained[topic] = payload
+	}
+	for _, s := range m.subscriptions {
+		if Match(s.filter, topic) {
+			s.push(message{topic: topic, payload: payload})
+		}
+	}
+
+	return nil
+}
+
+// Subscribe registers handler for topics matching filter, starting with any retained matches
+func (m *MemoryBroker) Subscribe(filter string, handler func(topic string, payload []byte)) error {
+	s := &subscription{
+		filter:  filter,
+		handler: handler,
+		waiting: make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return nil
+	}
+
+	m.subscriptions = append(m.subscriptions, s)
+	for topic, payload := range m.retained {
+		if Match(filter, topic) {
+			s.push(message{topic: topic, payload: payload})
+		}
+	}
+
+	go s.deliver()
+	return nil
+}
+
+// Close stops delivering messages. Handlers that are already running are left to finish.
+func (m *MemoryBroker) Close() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return
+	}
+	m.closed = true
+
+	for _, s := range m.subscriptions {
+		close(s.done)
+	}
+	m.subscriptions = nil
+}
+
+// Retained returns the retained payload for a topic, if there is one
+func (m *MemoryBroker) Retained(topic string) ([]byte, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	payload, ok := m.retained[topic]
+	return payload, ok
+}
+
+// push adds a message to the subscription's queue and wakes deliver up
+func (s *subscription) push(msg message) {
+	s.mu.Lock()
+	s.queue = append(s.queue, msg)
+	s.mu.Unlock()
+
+	select {
+	case s.waiting <- struct{}{}:
+	default:
+	}
+}
+
+// deliver hands queued messages to the handler one at a time until the broker is closed
+func (s *subscription) deliver() {
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-s.waiting:
+		}
+
+		for {
+			s.mu.Lock()
+			if len(s.queue) == 0 {
+				s.mu.Unlock()
+				break
+			}
+			msg := s.queue[0]
+			s.queue = s.queue[1:]
+			s.mu.Unlock()
+
+			s.handler(msg.topic, msg.payload)
+		}
+	}
+}
+
+// Match reports whether an MQTT topic filter, which may use the "+" and "#" wildcards, matches topic
+func Match(filter, topic string) bool {
+	filterLevels := strings.Split(filter, "/")
+	topicLevels := strings.Split(topic, "/")
+
+	for i, level := range filterLevels {
+		if level == "#" {
+			return true
+		}
+		if i >= len(topicLevels) {
+			return false
+		}
+		if level != "+" && level != topicLevels[i] {
+			return false
+		}
+	}
+
+	return len(filterLevels) == len(topicLevels)
+}
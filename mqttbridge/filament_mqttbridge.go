@@ -0,0 +1,276 @@
+package mqttbridge
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/panicpanicpanic/filament"
+	"github.com/panicpanicpanic/filament/device"
+	"github.com/panicpanicpanic/filament/lifx"
+)
+
+const (
+	// DefaultPrefix is the topic prefix used when a Bridge doesn't set one
+	DefaultPrefix = "lifx"
+
+	// DefaultInterval is how often a Bridge polls GetLights when it doesn't set an Interval
+	DefaultInterval = 30 * time.Second
+)
+
+// Broker is the part of an MQTT client a Bridge needs. MemoryBroker implements it in-process,
+// and PahoBroker implements it on top of a connection to a real broker.
+type Broker interface {
+	Publish(topic string, payload []byte, retained bool) error
+	Subscribe(filter string, handler func(topic string, payload []byte)) error
+}
+
+// Bridge publishes the state of every light to "<prefix>/<label>/state" and listens for commands on
+// "<prefix>/<label>/set", "<prefix>/<label>/toggle" and "<prefix>/<label>/effect". Lights that share
+// a label use their ID in place of the label, and commands sent to the shared label control all of them.
+type Bridge struct {
+	Client   *lifx.Client
+	Broker   Broker
+	Selector string
+	Prefix   string
+	Interval time.Duration
+
+	// OnError, if set, is called with errors from polling and commands, which would otherwise be dropped
+	OnError func(error)
+
+	once      sync.Once
+	mu        sync.Mutex
+	published map[string][]byte
+	ids       map[string]string
+	refresh   chan struct{}
+}
+
+// Run subscribes to the command topics and publishes light states until ctx is done
+func (b *Bridge) Run(ctx context.Context) error {
+	b.once.Do(b.setup)
+
+	for _, command := range []string{"set", "toggle", "effect"} {
+		command := command
+		err := b.Broker.Subscribe(b.Prefix+"/+/"+command, func(topic string, payload []byte) {
+			b.handle(ctx, command, topic, payload)
+		})
+		if err != nil {
+			return fmt.Errorf(err.Error())
+		}
+	}
+
+	ticker := time.NewTicker(b.Interval)
+	defer ticker.Stop()
+
+	for {
+		err := b.Poll(ctx)
+		if err != nil {
+			b.report(ctx, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		case <-b.refresh:
+		}
+	}
+}
+
+// Poll fetches the lights once and publishes every state that changed since the last Poll
+func (b *Bridge) Poll(ctx context.Context) error {
+	b.once.Do(b.setup)
+
+	client := *b.Client
+	client.Context = ctx
+
+	devices, err := filament.GetLights(&client, b.Selector)
+	if err != nil {
+		return err
+	}
+
+	names := topicNames(devices)
+	ids := make(map[string]string)
+
+	for _, d := range devices {
+		name := names[d.ID]
+		ids[name] = d.ID
+
+		state, err := json.Marshal(d)
+		if err != nil {
+			return fmt.Errorf(err.Error())
+		}
+
+		// Compare states without the fields that change on every poll, so only real changes are republished
+		stable := d
+		stable.LastSeen = time.Time{}
+		stable.SecondsSinceSeen = 0
+
+		compared, err := json.Marshal(stable)
+		if err != nil {
+			return fmt.Errorf(err.Error())
+		}
+
+		b.mu.Lock()
+		unchanged := bytes.Equal(b.published[name], compared)
+		b.mu.Unlock()
+
+		if unchanged {
+			continue
+		}
+
+		err = b.Broker.Publish(b.Prefix+"/"+name+"/state", state, true)
+		if err != nil {
+			return fmt.Errorf(err.Error())
+		}
+
+		b.mu.Lock()
+		b.published[name] = compared
+		b.mu.Unlock()
+	}
+
+	// Clear the retained state of lights that have gone, so new subscribers aren't told about them
+	b.mu.Lock()
+	var gone []string
+	for name := range b.published {
+		if _, ok := ids[name]; !ok {
+			gone = append(gone, name)
+		}
+	}
+	b.ids = ids
+	b.mu.Unlock()
+
+	for _, name := range gone {
+		err = b.Broker.Publish(b.Prefix+"/"+name+"/state", nil, true)
+		if err != nil {
+			return fmt.Errorf(err.Error())
+		}
+
+		b.mu.Lock()
+		delete(b.published, name)
+		b.mu.Unlock()
+	}
+
+	return nil
+}
+
+func (b *Bridge) setup() {
+	b.published = make(map[string][]byte)
+	b.ids = make(map[string]string)
+	b.refresh = make(chan struct{}, 1)
+
+	if b.Prefix == "" {
+		b.Prefix = DefaultPrefix
+	}
+	if b.Interval <= 0 {
+		b.Interval = DefaultInterval
+	}
+}
+
+// handle maps a command topic onto the matching filament function
+func (b *Bridge) handle(ctx context.Context, command, topic string, payload []byte) {
+	var err error
+
+	client := *b.Client
+	client.Context = ctx
+	name := strings.TrimSuffix(strings.TrimPrefix(topic, b.Prefix+"/"), "/"+command)
+
+	// Topic names never contain these, and in a label selector they would reach other lights
+	if strings.ContainsAny(name, ",|") {
+		b.report(ctx, fmt.Errorf("%s: topic names can't contain \",\" or \"|\"", topic))
+		return
+	}
+	selector := b.selector(name)
+
+	switch command {
+	case "set":
+		_, err = filament.SetState(&client, selector, json.RawMessage(payload))
+	case "toggle":
+		_, err = filament.TogglePower(&client, selector)
+	case "effect":
+		err = b.effect(&client, selector, payload)
+	}
+
+	if err != nil {
+		b.report(ctx, fmt.Errorf("%s: %s", topic, err))
+		return
+	}
+
+	// Publish the result of the command without waiting for the next poll
+	select {
+	case b.refresh <- struct{}{}:
+	default:
+	}
+}
+
+// effect runs the effect named by the payload's "effect" field, passing the rest of the payload to LIFX
+func (b *Bridge) effect(client *lifx.Client, selector string, payload []byte) error {
+	var fields map[string]interface{}
+
+	err := json.Unmarshal(payload, &fields)
+	if err != nil {
+		return fmt.Errorf(err.Error())
+	}
+
+	name, _ := fields["effect"].(string)
+	delete(fields, "effect")
+
+	switch name {
+	case "pulse":
+		_, err = filament.PulseEffect(client, selector, fields)
+	case "breathe":
+		_, err = filament.BreatheEffect(client, selector, fields)
+	default:
+		err = fmt.Errorf("unknown effect %q, expected \"pulse\" or \"breathe\"", name)
+	}
+
+	return err
+}
+
+// selector targets the light by ID once it has been polled, since topic names may not match labels exactly
+func (b *Bridge) selector(name string) string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if id, ok := b.ids[name]; ok {
+		return "id:" + id
+	}
+	return "label:" + name
+}
+
+func (b *Bridge) report(ctx context.Context, err error) {
+	// Errors caused by the Bridge shutting down aren't worth reporting
+	if b.OnError != nil && ctx.Err() == nil {
+		b.OnError(err)
+	}
+}
+
+// topicNames maps each device ID to its topic name. Lights sharing a label would publish over each
+// other's state, so they use their IDs instead.
+func topicNames(devices []device.Device) map[string]string {
+	labels := make(map[string]int)
+	for _, d := range devices {
+		labels[TopicName(d.Label)]++
+	}
+
+	names := make(map[string]string)
+	for _, d := range devices {
+		name := TopicName(d.Label)
+		if labels[name] > 1 || name == "" {
+			name = d.ID
+		}
+		names[d.ID] = name
+	}
+
+	return names
+}
+
+// TopicName turns a light's label into a single MQTT topic level, replacing the characters MQTT reserves
+// and the ones that have a meaning in LIFX selectors
+func TopicName(label string) string {
+	return strings.NewReplacer("/", "_", "+", "_", "#", "_", ",", "_", "|", "_").Replace(label)
+}
@@ -0,0 +1,261 @@
+package mqttbridge_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/panicpanicpanic/filament/lifx"
+	"github.com/panicpanicpanic/filament/mqttbridge"
+)
+
+func TestBridge(t *testing.T) {
+	requests := make(chan string, 10)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`[{"id": "d073d5000000", "label": "Desk/Lamp", "connected": true, "power": "on", "brightness": 1}]`))
+			return
+		}
+
+		body, _ := ioutil.ReadAll(r.Body)
+		requests <- r.Method + " " + r.URL.Path + " " + string(body)
+		w.WriteHeader(http.StatusMultiStatus)
+		w.Write([]byte(`{"results": [{"id": "d073d5000000", "status": "ok", "label": "Desk/Lamp"}]}`))
+	}))
+	defer server.Close()
+
+	broker := mqttbridge.NewMemoryBroker()
+	defer broker.Close()
+	bridge := &mqttbridge.Bridge{
+		Client: &lifx.Client{AccessToken: "someRandomToken", BaseURL: server.URL},
+		Broker: broker,
+		OnError: func(err error) {
+			t.Errorf("it should not have failed, got %s", err)
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go bridge.Run(ctx)
+
+	t.Run("when a light is polled", func(t *testing.T) {
+		deadline := time.Now().Add(2 * time.Second)
+		for time.Now().Before(deadline) {
+			if state, ok := broker.Retained("lifx/Desk_Lamp/state"); ok {
+				if !strings.Contains(string(state), `"power":"on"`) {
+					t.Errorf("it should have published the light's state, got %s", state)
+				}
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		t.Fatalf("it should have published a retained state for the light")
+	})
+
+	t.Run("when a set command is published", func(t *testing.T) {
+		broker.Publish("lifx/Desk_Lamp/set", []byte(`{"power":"off"}`), false)
+
+		select {
+		case request := <-requests:
+			if request != `PUT /lights/id:d073d5000000/state {"power":"off"}` {
+				t.Errorf("it should have called SetState for the light, got %s", request)
+			}
+		case <-time.After(2 * time.Second):
+			t.Errorf("it should have forwarded the command to LIFX")
+		}
+	})
+
+	t.Run("when an effect command is published", func(t *testing.T) {
+		broker.Publish("lifx/Desk_Lamp/effect", []byte(`{"effect":"breathe","color":"red"}`), false)
+
+		select {
+		case request := <-requests:
+			if request != `POST /lights/id:d073d5000000/effects/breathe {"color":"red"}` {
+				t.Errorf("it should have called BreatheEffect for the light, got %s", request)
+			}
+		case <-time.After(2 * time.Second):
+			t.Errorf("it should have forwarded the effect to LIFX")
+		}
+	})
+}
+
+// recorder collects the topics a MemoryBroker delivers to a subscriber
+type recorder struct {
+	broker *mqttbridge.MemoryBroker
+	topics chan string
+}
+
+func newRecorder(broker *mqttbridge.MemoryBroker, filter string) *recorder {
+	r := &recorder{broker: broker, topics: make(chan string, 100)}
+	broker.Subscribe(filter, func(topic string, payload []byte) {
+		if len(payload) == 0 {
+			topic += " (cleared)"
+		}
+		r.topics <- topic
+	})
+	return r
+}
+
+// delivered returns every topic delivered so far. Each subscriber gets its messages in order, so once
+// a marker published now comes through, everything published before it has too.
+func (r *recorder) delivered(t *testing.T) []string {
+	var topics []string
+
+	r.broker.Publish("lifx/marker/state", []byte("marker"), false)
+	for {
+		select {
+		case topic := <-r.topics:
+			if topic == "lifx/marker/state" {
+				return topics
+			}
+			topics = append(topics, topic)
+		case <-time.After(2 * time.Second):
+			t.Fatalf("it should have delivered the marker, got %v", topics)
+		}
+	}
+}
+
+func TestBridgePoll(t *testing.T) {
+	lights := `[{"id": "d1", "label": "Desk", "power": "on", "seconds_since_seen": 1}]`
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(lights))
+	}))
+	defer server.Close()
+
+	broker := mqttbridge.NewMemoryBroker()
+	defer broker.Close()
+	record := newRecorder(broker, "lifx/+/state")
+
+	bridge := &mqttbridge.Bridge{
+		Client: &lifx.Client{AccessToken: "someRandomToken", BaseURL: server.URL},
+		Broker: broker,
+	}
+
+	t.Run("when only the last seen time changes", func(t *testing.T) {
+		bridge.Poll(context.Background())
+		lights = `[{"id": "d1", "label": "Desk", "power": "on", "seconds_since_seen": 31}]`
+		bridge.Poll(context.Background())
+
+		if published := record.delivered(t); len(published) != 1 {
+			t.Errorf("it should have published the state once, got %v", published)
+		}
+	})
+
+	t.Run("when two lights share a label", func(t *testing.T) {
+		lights = `[{"id": "d1", "label": "Lamp", "power": "on"}, {"id": "d2", "label": "Lamp", "power": "off"}]`
+		bridge.Poll(context.Background())
+
+		published := record.delivered(t)
+		if len(published) != 3 || published[0] != "lifx/d1/state" || published[1] != "lifx/d2/state" {
+			t.Errorf("it should have published each light under its ID, got %v", published)
+		}
+		if published[2] != "lifx/Desk/state (cleared)" {
+			t.Errorf("it should have cleared the state of the light that went, got %v", published)
+		}
+		if _, ok := broker.Retained("lifx/Desk/state"); ok {
+			t.Errorf("it should not have kept a retained state for the light that went")
+		}
+	})
+}
+
+func TestBridgeTopics(t *testing.T) {
+	requests := make(chan string, 10)
+	polled := make(chan struct{}, 10)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			w.Write([]byte(`[]`))
+			polled <- struct{}{}
+			return
+		}
+		requests <- r.Method + " " + r.URL.Path
+		w.WriteHeader(http.StatusMultiStatus)
+		w.Write([]byte(`{"results": []}`))
+	}))
+	defer server.Close()
+
+	broker := mqttbridge.NewMemoryBroker()
+	defer broker.Close()
+
+	errs := make(chan error, 10)
+	bridge := &mqttbridge.Bridge{
+		Client:  &lifx.Client{AccessToken: "someRandomToken", BaseURL: server.URL},
+		Broker:  broker,
+		OnError: func(err error) { errs <- err },
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go bridge.Run(ctx)
+
+	// Run subscribes to the command topics before it first polls
+	<-polled
+
+	t.Run("when a command topic names more than one light", func(t *testing.T) {
+		broker.Publish("lifx/Desk,all/toggle", nil, false)
+
+		select {
+		case err := <-errs:
+			if !strings.Contains(err.Error(), "lifx/Desk,all/toggle") {
+				t.Errorf("it should have reported the topic, got %s", err)
+			}
+		case request := <-requests:
+			t.Errorf("it should not have called LIFX, got %s", request)
+		case <-time.After(2 * time.Second):
+			t.Errorf("it should have rejected the topic")
+		}
+	})
+
+	t.Run("when a label has selector characters", func(t *testing.T) {
+		if name := mqttbridge.TopicName("Desk, Left|Right"); name != "Desk_ Left_Right" {
+			t.Errorf("it should have replaced them, got %s", name)
+		}
+	})
+}
+
+func TestMemoryBroker(t *testing.T) {
+	broker := mqttbridge.NewMemoryBroker()
+	defer broker.Close()
+
+	blocked := make(chan struct{})
+	defer close(blocked)
+	broker.Subscribe("lifx/#", func(topic string, payload []byte) {
+		<-blocked
+	})
+	record := newRecorder(broker, "lifx/+/state")
+
+	t.Run("when a subscriber is slow", func(t *testing.T) {
+		published := make(chan struct{})
+		go func() {
+			broker.Publish("lifx/Desk/state", []byte("on"), true)
+			broker.Publish("lifx/Lamp/state", []byte("off"), true)
+			close(published)
+		}()
+
+		select {
+		case <-published:
+		case <-time.After(2 * time.Second):
+			t.Fatalf("it should not have held up the publisher")
+		}
+
+		if topics := record.delivered(t); len(topics) != 2 || topics[0] != "lifx/Desk/state" || topics[1] != "lifx/Lamp/state" {
+			t.Errorf("it should have delivered to the other subscriber in order, got %v", topics)
+		}
+	})
+}
+
+func TestMatch(t *testing.T) {
+	if !mqttbridge.Match("lifx/+/set", "lifx/Desk/set") || mqttbridge.Match("lifx/+/set", "lifx/Desk/toggle") {
+		t.Errorf("it should have matched single level wildcards")
+	}
+	if !mqttbridge.Match("lifx/#", "lifx/Desk/state") {
+		t.Errorf("it should have matched multi level wildcards")
+	}
+}
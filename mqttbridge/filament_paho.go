@@ -0,0 +1,43 @@
+package mqttbridge
+
+import (
+	"fmt"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+)
+
+// PahoBroker is a Broker backed by a connection to a real MQTT broker, such as Mosquitto
+type PahoBroker struct {
+	Client paho.Client
+	QoS    byte
+}
+
+// Dial connects to the MQTT broker at url (e.g. "tcp://localhost:1883") and returns a PahoBroker
+func Dial(url, clientID string) (*PahoBroker, error) {
+	options := paho.NewClientOptions().AddBroker(url).SetClientID(clientID)
+	client := paho.NewClient(options)
+
+	token := client.Connect()
+	token.Wait()
+	if token.Error() != nil {
+		return nil, fmt.Errorf(token.Error().Error())
+	}
+
+	return &PahoBroker{Client: client, QoS: 1}, nil
+}
+
+// Publish sends the payload to the broker and waits for it to be acknowledged
+func (p *PahoBroker) Publish(topic string, payload []byte, retained bool) error {
+	token := p.Client.Publish(topic, p.QoS, retained, payload)
+	token.Wait()
+	return token.Error()
+}
+
+// Subscribe registers handler for topics matching filter
+func (p *PahoBroker) Subscribe(filter string, handler func(topic string, payload []byte)) error {
+	token := p.Client.Subscribe(filter, p.QoS, func(_ paho.Client, message paho.Message) {
+		handler(message.Topic(), message.Payload())
+	})
+	token.Wait()
+	return token.Error()
+}
@@ -0,0 +1,251 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/panicpanicpanic/filament/device"
+	"github.com/panicpanicpanic/filament/watcher"
+)
+
+const (
+	// DefaultRetryDelay is how long the first retry of a failed webhook waits when RetryDelay isn't set.
+	// Each retry after that waits twice as long.
+	DefaultRetryDelay = 500 * time.Millisecond
+
+	// DefaultTimeout bounds each webhook post when Timeout isn't set
+	DefaultTimeout = 10 * time.Second
+)
+
+// Event is the JSON posted to each webhook. Text makes it readable by Slack-style incoming webhooks.
+type Event struct {
+	Text   string        `json:"text"`
+	Rule   string        `json:"rule"`
+	Time   time.Time     `json:"time"`
+	Device device.Device `json:"device"`
+}
+
+// Notifier evaluates Rules against the lights reported by a watcher.Watcher and posts an Event to
+// every webhook when one matches
+type Notifier struct {
+	Webhooks []string
+	Rules    []Rule
+
+	// HTTPClient, if set, posts the Events
+	HTTPClient *http.Client
+
+	// Retries is how many times a webhook that failed is tried again
+	Retries    int
+	RetryDelay time.Duration
+
+	// Timeout bounds each post to a webhook, defaulting to DefaultTimeout
+	Timeout time.Duration
+
+	// Now, if set, is used instead of time.Now, e.g. to test time based rules
+	Now func() time.Time
+
+	// OnError, if set, is called when a webhook couldn't be delivered
+	OnError func(error)
+
+	mutex  sync.Mutex
+	lights map[string]*Light
+
+	// active holds the index of every Rule currently matching each light, by light ID
+	active map[string]map[int]bool
+
+	deliveries sync.WaitGroup
+}
+
+// Run watches the lights with w until ctx is done, notifying as Rules match. It waits for the
+// webhooks still being posted before returning.
+func (n *Notifier) Run(ctx context.Context, w *watcher.Watcher) error {
+	err := w.Run(ctx, func(_ []device.Device, changes []watcher.Change) {
+		n.Observe(ctx, changes)
+	})
+
+	n.Wait()
+	return err
+}
+
+// Observe updates what the Notifier knows about the lights from changes, then evaluates every Rule
+// against every light, returning the Events that are new. The Events are posted to the webhooks in
+// the background, so a slow webhook doesn't hold up watching the lights.
+func (n *Notifier) Observe(ctx context.Context, changes []watcher.Change) []Event {
+	n.mutex.Lock()
+	now := n.now()
+	n.update(now, changes)
+	events := n.evaluate(now)
+	n.mutex.Unlock()
+
+	if len(events) == 0 || len(n.Webhooks) == 0 {
+		return events
+	}
+
+	n.deliveries.Add(1)
+	go func() {
+		defer n.deliveries.Done()
+
+		for _, event := range events {
+			for _, webhook := range n.Webhooks {
+				err := n.deliver(ctx, webhook, event)
+				if err != nil && n.OnError != nil {
+					n.OnError(err)
+				}
+			}
+		}
+	}()
+
+	return events
+}
+
+// Wait blocks until every Event returned by Observe so far has been delivered or given up on
+func (n *Notifier) Wait() {
+	n.deliveries.Wait()
+}
+
+func (n *Notifier) update(now time.Time, changes []watcher.Change) {
+	if n.lights == nil {
+		n.lights = map[string]*Light{}
+		n.active = map[string]map[int]bool{}
+	}
+
+	for _, change := range changes {
+		if change.After == nil {
+			delete(n.lights, change.Before.ID)
+			delete(n.active, change.Before.ID)
+			continue
+		}
+
+		light, ok := n.lights[change.After.ID]
+		if !ok {
+			light = &Light{}
+			n.lights[change.After.ID] = light
+		}
+		light.Device = *change.After
+
+		if light.Connected {
+			light.DisconnectedSince = time.Time{}
+		} else if light.DisconnectedSince.IsZero() {
+			light.DisconnectedSince = now
+			if !light.LastSeen.IsZero() && light.LastSeen.Before(now) {
+				light.DisconnectedSince = light.LastSeen
+			}
+		}
+
+		if light.Power != "on" {
+			light.OnSince = time.Time{}
+		} else if change.Before != nil && change.Before.Power != "on" {
+			light.OnSince = now
+		}
+	}
+}
+
+// evaluate checks every Rule against every light, deduping alerts that are still active. Rules are
+// told apart by their index, since several Rules of one kind can share a name.
+func (n *Notifier) evaluate(now time.Time) []Event {
+	var ids []string
+	for id := range n.lights {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	var events []Event
+	for _, id := range ids {
+		light := n.lights[id]
+		if n.active[id] == nil {
+			n.active[id] = map[int]bool{}
+		}
+
+		for i, rule := range n.Rules {
+			message, matched := rule.Check(now, *light)
+			if !matched {
+				delete(n.active[id], i)
+				continue
+			}
+			if n.active[id][i] {
+				continue
+			}
+
+			n.active[id][i] = true
+			events = append(events, Event{Text: message, Rule: rule.Name, Time: now, Device: light.Device})
+		}
+	}
+
+	return events
+}
+
+// deliver posts event to webhook, retrying with backoff on network errors and non-2xx responses
+func (n *Notifier) deliver(ctx context.Context, webhook string, event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf(err.Error())
+	}
+
+	client := n.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	delay := n.RetryDelay
+	if delay <= 0 {
+		delay = DefaultRetryDelay
+	}
+
+	for attempt := 0; ; attempt++ {
+		err = n.post(ctx, client, webhook, body)
+		if err == nil || attempt >= n.Retries {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay << uint(attempt)):
+		}
+	}
+}
+
+func (n *Notifier) post(ctx context.Context, client *http.Client, webhook string, body []byte) error {
+	timeout := n.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	request, err := http.NewRequest("POST", webhook, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf(err.Error())
+	}
+	request = request.WithContext(ctx)
+	request.Header.Set("Content-Type", "application/json")
+
+	response, err := client.Do(request)
+	if err != nil {
+		return fmt.Errorf(err.Error())
+	}
+	defer response.Body.Close()
+	io.Copy(ioutil.Discard, response.Body)
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return fmt.Errorf("webhook %s returned a %d status code", webhook, response.StatusCode)
+	}
+
+	return nil
+}
+
+func (n *Notifier) now() time.Time {
+	if n.Now != nil {
+		return n.Now()
+	}
+	return time.Now()
+}
@@ -0,0 +1,230 @@
+package notifier_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/panicpanicpanic/filament/device"
+	"github.com/panicpanicpanic/filament/notifier"
+	"github.com/panicpanicpanic/filament/watcher"
+)
+
+func TestNotifier(t *testing.T) {
+	var mutex sync.Mutex
+	var received []notifier.Event
+	var failures int
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		if failures > 0 {
+			failures--
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		var event notifier.Event
+		json.NewDecoder(r.Body).Decode(&event)
+		received = append(received, event)
+	}))
+	defer server.Close()
+
+	now := time.Date(2026, 1, 1, 22, 0, 0, 0, time.UTC)
+	n := &notifier.Notifier{
+		Webhooks:   []string{server.URL},
+		Rules:      []notifier.Rule{notifier.Disconnected(10 * time.Minute), notifier.PowerOnAtNight(23, 6), notifier.BrightnessAbove(0.9)},
+		Retries:    2,
+		RetryDelay: time.Millisecond,
+		Now:        func() time.Time { return now },
+	}
+
+	online := device.Device{ID: "1", Label: "Lamp", Connected: true, Power: "off"}
+	offline := online
+	offline.Connected = false
+	ctx := context.Background()
+
+	t.Run("when a light has only just gone offline", func(t *testing.T) {
+		events := n.Observe(ctx, watcher.Diff(nil, []device.Device{offline}))
+		if len(events) != 0 {
+			t.Errorf("it should not have alerted yet, got %+v", events)
+		}
+	})
+
+	t.Run("when a light has been offline long enough", func(t *testing.T) {
+		now = now.Add(15 * time.Minute)
+
+		events := n.Observe(ctx, nil)
+		if len(events) != 1 || events[0].Rule != "disconnected" || events[0].Text != "Lamp has been offline for 15m0s" {
+			t.Fatalf("it should have alerted that the light is offline, got %+v", events)
+		}
+
+		n.Wait()
+		if len(received) != 1 || received[0].Device.ID != "1" {
+			t.Errorf("it should have posted the event to the webhook, got %+v", received)
+		}
+	})
+
+	t.Run("when the light stays offline", func(t *testing.T) {
+		now = now.Add(15 * time.Minute)
+
+		if events := n.Observe(ctx, nil); len(events) != 0 {
+			t.Errorf("it should not have alerted twice, got %+v", events)
+		}
+	})
+
+	t.Run("when the light is turned on at night", func(t *testing.T) {
+		now = time.Date(2026, 1, 2, 1, 30, 0, 0, time.UTC)
+		bright := online
+		bright.Power = "on"
+		bright.Brightness = 1
+
+		mutex.Lock()
+		failures = 1
+		received = nil
+		mutex.Unlock()
+
+		events := n.Observe(ctx, watcher.Diff([]device.Device{offline}, []device.Device{bright}))
+		if len(events) != 2 || events[0].Rule != "power_on_at_night" || events[1].Rule != "brightness_above" {
+			t.Fatalf("it should have alerted for the night and the brightness, got %+v", events)
+		}
+
+		n.Wait()
+		if len(received) != 2 {
+			t.Errorf("it should have retried the failed webhook, got %d deliveries", len(received))
+		}
+	})
+
+	t.Run("when the light goes offline again after recovering", func(t *testing.T) {
+		n.Observe(ctx, watcher.Diff([]device.Device{online}, []device.Device{offline}))
+		now = now.Add(11 * time.Minute)
+
+		events := n.Observe(ctx, nil)
+		if len(events) != 1 || events[0].Rule != "disconnected" {
+			t.Errorf("it should have alerted again, got %+v", events)
+		}
+	})
+}
+
+func TestNotifierRules(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	n := &notifier.Notifier{
+		Rules: []notifier.Rule{notifier.Disconnected(10 * time.Minute), notifier.Disconnected(time.Hour)},
+		Now:   func() time.Time { return now },
+	}
+
+	offline := device.Device{ID: "1", Label: "Lamp"}
+	n.Observe(context.Background(), watcher.Diff(nil, []device.Device{offline}))
+
+	t.Run("when two rules of the same kind match in turn", func(t *testing.T) {
+		now = now.Add(15 * time.Minute)
+		first := n.Observe(context.Background(), nil)
+
+		now = now.Add(time.Hour)
+		second := n.Observe(context.Background(), nil)
+
+		if len(first) != 1 || len(second) != 1 || second[0].Text != "Lamp has been offline for 1h15m0s" {
+			t.Errorf("it should have alerted for each rule, got %+v and %+v", first, second)
+		}
+	})
+
+	t.Run("when a light disappears and comes back", func(t *testing.T) {
+		n.Observe(context.Background(), watcher.Diff([]device.Device{offline}, nil))
+		n.Observe(context.Background(), watcher.Diff(nil, []device.Device{offline}))
+		now = now.Add(2 * time.Hour)
+
+		if events := n.Observe(context.Background(), nil); len(events) != 2 {
+			t.Errorf("it should have forgotten the light's old alerts, got %+v", events)
+		}
+	})
+}
+
+func TestNotifierWebhooks(t *testing.T) {
+	release := make(chan struct{})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+
+	n := &notifier.Notifier{
+		Webhooks: []string{server.URL},
+		Rules:    []notifier.Rule{notifier.BrightnessAbove(0.5)},
+	}
+
+	t.Run("when a webhook is slow", func(t *testing.T) {
+		bright := device.Device{ID: "1", Label: "Lamp", Power: "on", Brightness: 1}
+
+		done := make(chan []notifier.Event)
+		go func() {
+			done <- n.Observe(context.Background(), watcher.Diff(nil, []device.Device{bright}))
+		}()
+
+		select {
+		case events := <-done:
+			if len(events) != 1 {
+				t.Errorf("it should have returned the event, got %+v", events)
+			}
+		case <-time.After(time.Second):
+			t.Errorf("it should not have waited for the webhook")
+		}
+
+		close(release)
+		n.Wait()
+	})
+
+	t.Run("when a webhook never answers", func(t *testing.T) {
+		hung := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(200 * time.Millisecond)
+		}))
+		defer hung.Close()
+
+		var failed error
+		n := &notifier.Notifier{
+			Webhooks: []string{hung.URL},
+			Rules:    []notifier.Rule{notifier.BrightnessAbove(0.5)},
+			Timeout:  10 * time.Millisecond,
+			OnError:  func(err error) { failed = err },
+		}
+
+		n.Observe(context.Background(), watcher.Diff(nil, []device.Device{{ID: "1", Power: "on", Brightness: 1}}))
+		n.Wait()
+
+		if failed == nil {
+			t.Errorf("it should have given up on the webhook after the timeout")
+		}
+	})
+}
+
+func TestPowerOnAtNight(t *testing.T) {
+	rule := notifier.PowerOnAtNight(23, 6)
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	light := notifier.Light{Device: device.Device{Label: "Lamp", Power: "on"}}
+
+	t.Run("when the light was turned on during the day", func(t *testing.T) {
+		light.OnSince = now
+		if _, matched := rule.Check(now, light); matched {
+			t.Errorf("it should not have matched")
+		}
+	})
+
+	t.Run("when the light was already on", func(t *testing.T) {
+		light.OnSince = time.Time{}
+		if _, matched := rule.Check(now, light); matched {
+			t.Errorf("it should not have matched")
+		}
+	})
+
+	t.Run("when the light was turned on just before midnight", func(t *testing.T) {
+		light.OnSince = time.Date(2026, 1, 1, 23, 15, 0, 0, time.UTC)
+		message, matched := rule.Check(now, light)
+		if !matched || message != "Lamp was turned on at 23:15" {
+			t.Errorf("it should have matched, got %q", message)
+		}
+	})
+}
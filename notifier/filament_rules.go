@@ -0,0 +1,85 @@
+package notifier
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/panicpanicpanic/filament/device"
+)
+
+// Light is a light as the Notifier has come to know it, with when it entered its current state
+type Light struct {
+	device.Device
+
+	// DisconnectedSince is when the light was last seen, or zero while it is connected
+	DisconnectedSince time.Time
+
+	// OnSince is when the light was seen turning on, or zero if it is off or was already on when
+	// the Notifier first saw it
+	OnSince time.Time
+}
+
+// Rule decides whether a light deserves an alert. Check returns the message to send while the
+// rule's condition holds; the Notifier only sends it once until the condition clears.
+type Rule struct {
+	Name  string
+	Check func(now time.Time, light Light) (string, bool)
+}
+
+// Disconnected alerts when a light has been offline for at least after
+func Disconnected(after time.Duration) Rule {
+	return Rule{
+		Name: "disconnected",
+		Check: func(now time.Time, light Light) (string, bool) {
+			if light.Connected || light.DisconnectedSince.IsZero() {
+				return "", false
+			}
+
+			offline := now.Sub(light.DisconnectedSince)
+			if offline < after {
+				return "", false
+			}
+
+			return fmt.Sprintf("%s has been offline for %s", light.Label, offline.Truncate(time.Minute)), true
+		},
+	}
+}
+
+// PowerOnAtNight alerts when a light is turned on between the from and to hours, in now's time
+// zone. The window wraps past midnight when from is after to, e.g. PowerOnAtNight(23, 6).
+func PowerOnAtNight(from, to int) Rule {
+	return Rule{
+		Name: "power_on_at_night",
+		Check: func(now time.Time, light Light) (string, bool) {
+			if light.Power != "on" || light.OnSince.IsZero() {
+				return "", false
+			}
+
+			on := light.OnSince.In(now.Location())
+			hour := on.Hour()
+			night := hour >= from && hour < to
+			if from > to {
+				night = hour >= from || hour < to
+			}
+			if !night {
+				return "", false
+			}
+
+			return fmt.Sprintf("%s was turned on at %s", light.Label, on.Format("15:04")), true
+		},
+	}
+}
+
+// BrightnessAbove alerts when a light is on brighter than threshold, between 0 and 1
+func BrightnessAbove(threshold float64) Rule {
+	return Rule{
+		Name: "brightness_above",
+		Check: func(now time.Time, light Light) (string, bool) {
+			if light.Power != "on" || light.Brightness <= threshold {
+				return "", false
+			}
+
+			return fmt.Sprintf("%s is at %.0f%% brightness", light.Label, light.Brightness*100), true
+		},
+	}
+}
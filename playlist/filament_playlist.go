@@ -0,0 +1,268 @@
+package playlist
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/panicpanicpanic/filament"
+	"github.com/panicpanicpanic/filament/config"
+	"github.com/panicpanicpanic/filament/lifx"
+)
+
+// Playlist is a named list of states to cycle through
+type Playlist struct {
+	Name   string                   `json:"name"`
+	States []map[string]interface{} `json:"states"`
+
+	// Defaults are applied to every state that doesn't set them itself, e.g. a duration
+	Defaults map[string]interface{} `json:"defaults,omitempty"`
+}
+
+// Store keeps Playlists, and how far each selector has got through them, in a JSON file
+type Store struct {
+	Path string
+
+	mutex sync.Mutex
+}
+
+// file is the contents of a Store's file
+type file struct {
+	Playlists map[string]Playlist `json:"playlists"`
+
+	// Positions are keyed by playlist name and selector
+	Positions map[string]int `json:"positions"`
+}
+
+// DefaultPath is where Playlists are stored, next to the filament config file
+func DefaultPath() string {
+	return filepath.Join(filepath.Dir(config.DefaultPath()), "playlists.json")
+}
+
+// Save adds playlist to the Store, replacing any with the same name and starting it from the top
+func (s *Store) Save(playlist Playlist) error {
+	if playlist.Name == "" {
+		return fmt.Errorf("playlist has no name")
+	}
+	if len(playlist.States) == 0 {
+		return fmt.Errorf("playlist %q has no states", playlist.Name)
+	}
+
+	return s.update(func(f *file) error {
+		f.Playlists[playlist.Name] = playlist
+		resetPositions(f, playlist.Name)
+		return nil
+	})
+}
+
+// Delete removes the named Playlist
+func (s *Store) Delete(name string) error {
+	return s.update(func(f *file) error {
+		if _, ok := f.Playlists[name]; !ok {
+			return fmt.Errorf("no playlist named %q", name)
+		}
+		delete(f.Playlists, name)
+		resetPositions(f, name)
+		return nil
+	})
+}
+
+// Get returns the named Playlist
+func (s *Store) Get(name string) (Playlist, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	f, err := s.read()
+	if err != nil {
+		return Playlist{}, err
+	}
+
+	playlist, ok := f.Playlists[name]
+	if !ok {
+		return Playlist{}, fmt.Errorf("no playlist named %q", name)
+	}
+	return playlist, nil
+}
+
+// Names returns the names of every Playlist, sorted
+func (s *Store) Names() ([]string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	f, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for name := range f.Playlists {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Position returns the index of the state the lights within selector were last set to from the named
+// Playlist, or -1 if they haven't been set from it yet
+func (s *Store) Position(name, selector string) (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	f, err := s.read()
+	if err != nil {
+		return -1, err
+	}
+
+	position, ok := f.Positions[key(name, selector)]
+	if !ok {
+		return -1, nil
+	}
+	return position, nil
+}
+
+// Next sets the lights within selector to the next state in the named Playlist, wrapping around at
+// the end. The first call sets the first state.
+func (s *Store) Next(client *lifx.Client, name, selector string) (lifx.Response, error) {
+	return s.step(client, name, selector, 1)
+}
+
+// Previous sets the lights within selector to the previous state in the named Playlist, wrapping
+// around at the start. The first call sets the last state.
+func (s *Store) Previous(client *lifx.Client, name, selector string) (lifx.Response, error) {
+	return s.step(client, name, selector, -1)
+}
+
+// step moves through the Playlist by direction. The position is tracked here rather than left to
+// LIFX's Cycle matching, so it works even when the lights' state doesn't match any entry exactly.
+func (s *Store) step(client *lifx.Client, name, selector string, direction int) (lifx.Response, error) {
+	var state map[string]interface{}
+	var position int
+
+	s.mutex.Lock()
+	f, err := s.read()
+	if err == nil {
+		playlist, ok := f.Playlists[name]
+		if !ok {
+			err = fmt.Errorf("no playlist named %q", name)
+		} else {
+			current, started := f.Positions[key(name, selector)]
+			if !started {
+				current = -1
+				if direction < 0 {
+					current = 0
+				}
+			}
+
+			count := len(playlist.States)
+			position = ((current+direction)%count + count) % count
+			state = withDefaults(playlist.States[position], playlist.Defaults)
+		}
+	}
+	s.mutex.Unlock()
+	if err != nil {
+		return lifx.Response{}, err
+	}
+
+	response, err := filament.SetState(client, selector, state)
+	if err != nil {
+		return response, err
+	}
+
+	// Only move once LIFX has accepted the state, so a failed call can simply be retried
+	return response, s.update(func(f *file) error {
+		f.Positions[key(name, selector)] = position
+		return nil
+	})
+}
+
+func (s *Store) update(change func(*file) error) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	f, err := s.read()
+	if err != nil {
+		return err
+	}
+
+	err = change(&f)
+	if err != nil {
+		return err
+	}
+
+	return s.write(f)
+}
+
+func (s *Store) read() (file, error) {
+	f := file{Playlists: map[string]Playlist{}, Positions: map[string]int{}}
+
+	body, err := ioutil.ReadFile(s.Path)
+	if os.IsNotExist(err) {
+		return f, nil
+	}
+	if err != nil {
+		return f, fmt.Errorf(err.Error())
+	}
+
+	err = json.Unmarshal(body, &f)
+	if err != nil {
+		return f, fmt.Errorf("%s: %s", s.Path, err.Error())
+	}
+	if f.Playlists == nil {
+		f.Playlists = map[string]Playlist{}
+	}
+	if f.Positions == nil {
+		f.Positions = map[string]int{}
+	}
+
+	return f, nil
+}
+
+func (s *Store) write(f file) error {
+	body, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return fmt.Errorf(err.Error())
+	}
+
+	err = os.MkdirAll(filepath.Dir(s.Path), 0700)
+	if err != nil {
+		return fmt.Errorf(err.Error())
+	}
+
+	// Write to a temporary file first so another process never reads half a file
+	temp := s.Path + ".tmp"
+	err = ioutil.WriteFile(temp, body, 0600)
+	if err != nil {
+		return fmt.Errorf(err.Error())
+	}
+
+	return os.Rename(temp, s.Path)
+}
+
+func key(name, selector string) string {
+	return name + "\x00" + selector
+}
+
+func resetPositions(f *file, name string) {
+	prefix := key(name, "")
+	for k := range f.Positions {
+		if strings.HasPrefix(k, prefix) {
+			delete(f.Positions, k)
+		}
+	}
+}
+
+func withDefaults(state, defaults map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(state)+len(defaults))
+	for field, value := range defaults {
+		merged[field] = value
+	}
+	for field, value := range state {
+		merged[field] = value
+	}
+	return merged
+}
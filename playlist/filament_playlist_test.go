@@ -0,0 +1,105 @@
+package playlist_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/panicpanicpanic/filament/lifx"
+	"github.com/panicpanicpanic/filament/playlist"
+)
+
+func TestStore(t *testing.T) {
+	var sent []map[string]interface{}
+	var fail bool
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		var state map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&state)
+		sent = append(sent, state)
+		w.WriteHeader(http.StatusMultiStatus)
+		w.Write([]byte(`{"results": [{"id": "d1", "status": "ok"}]}`))
+	}))
+	defer server.Close()
+
+	client := &lifx.Client{AccessToken: "someRandomToken", BaseURL: server.URL}
+	path := filepath.Join(t.TempDir(), "playlists.json")
+	store := &playlist.Store{Path: path}
+
+	err := store.Save(playlist.Playlist{
+		Name:     "evening",
+		States:   []map[string]interface{}{{"color": "red"}, {"color": "green"}, {"color": "blue", "duration": 5}},
+		Defaults: map[string]interface{}{"duration": 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("when cycling forward", func(t *testing.T) {
+		for _, expected := range []string{"red", "green", "blue", "red"} {
+			_, err := store.Next(client, "evening", "label:Desk")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if color := sent[len(sent)-1]["color"]; color != expected {
+				t.Errorf("it should have sent %s, got %v", expected, color)
+			}
+		}
+
+		if sent[0]["duration"] != 1.0 || sent[2]["duration"] != 5.0 {
+			t.Errorf("it should have applied defaults without overriding states, got %v", sent)
+		}
+	})
+
+	t.Run("when another selector cycles the same playlist", func(t *testing.T) {
+		store.Previous(client, "evening", "label:Porch")
+		if color := sent[len(sent)-1]["color"]; color != "blue" {
+			t.Errorf("it should have started from the end, got %v", color)
+		}
+		if position, _ := store.Position("evening", "label:Desk"); position != 0 {
+			t.Errorf("it should have kept the desk's position, got %d", position)
+		}
+	})
+
+	t.Run("when the store is reopened", func(t *testing.T) {
+		reopened := &playlist.Store{Path: path}
+		if position, _ := reopened.Position("evening", "label:Porch"); position != 2 {
+			t.Errorf("it should have persisted the position, got %d", position)
+		}
+		if names, _ := reopened.Names(); len(names) != 1 || names[0] != "evening" {
+			t.Errorf("it should have persisted the playlist, got %v", names)
+		}
+	})
+
+	t.Run("when LIFX rejects the state", func(t *testing.T) {
+		fail = true
+		defer func() { fail = false }()
+
+		if _, err := store.Next(client, "evening", "label:Desk"); err == nil {
+			t.Errorf("it should have returned the error")
+		}
+		if position, _ := store.Position("evening", "label:Desk"); position != 0 {
+			t.Errorf("it should not have moved, got %d", position)
+		}
+	})
+
+	t.Run("when the playlist does not exist", func(t *testing.T) {
+		if _, err := store.Next(client, "morning", "all"); err == nil {
+			t.Errorf("it should have returned an error")
+		}
+	})
+
+	t.Run("when the playlist is saved again", func(t *testing.T) {
+		store.Save(playlist.Playlist{Name: "evening", States: []map[string]interface{}{{"color": "white"}}})
+		if position, _ := store.Position("evening", "label:Desk"); position != -1 {
+			t.Errorf("it should have reset the positions, got %d", position)
+		}
+	})
+}
@@ -0,0 +1,353 @@
+package proxy
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/panicpanicpanic/filament"
+	"github.com/panicpanicpanic/filament/device"
+	"github.com/panicpanicpanic/filament/lifx"
+)
+
+// MaxPayload is the largest request body, in bytes, the proxy reads
+const MaxPayload = 1 << 20
+
+// zoneSuffix is the only thing allowed after a "|" in a selector: a zone or tile, or a range of them
+var zoneSuffix = regexp.MustCompile(`^\d+(-\d+)?$`)
+
+// Key is an API key the proxy accepts, along with the selectors callers using it may control
+type Key struct {
+	Name      string   `json:"name"`
+	Key       string   `json:"key"`
+	Selectors []string `json:"selectors"`
+}
+
+// Allows returns true if every part of the selector is on the Key's allowlist. Zone suffixes are
+// ignored, so allowing "id:d073d5000000" also allows "id:d073d5000000|0-5". Selectors containing
+// characters that could change the upstream URL once forwarded, or a suffix that isn't a zone range,
+// are never allowed.
+func (k Key) Allows(selector string) bool {
+	if selector == "" {
+		selector = "all"
+	}
+	if strings.ContainsAny(selector, "%/?#") {
+		return false
+	}
+
+	for _, part := range strings.Split(selector, ",") {
+		part = strings.TrimSpace(part)
+		if i := strings.Index(part, "|"); i >= 0 && !zoneSuffix.MatchString(part[i+1:]) {
+			return false
+		}
+		if !k.allowsOne(device.SelectorBase(part)) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (k Key) allowsOne(selector string) bool {
+	for _, allowed := range k.Selectors {
+		if allowed == "all" || allowed == selector {
+			return true
+		}
+	}
+	return false
+}
+
+// LoadKeys reads a JSON array of Keys from the file at path
+func LoadKeys(path string) ([]Key, error) {
+	var keys []Key
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return keys, fmt.Errorf(err.Error())
+	}
+
+	err = json.Unmarshal(data, &keys)
+	if err != nil {
+		return keys, fmt.Errorf(err.Error())
+	}
+
+	for i, key := range keys {
+		if key.Key == "" {
+			return nil, fmt.Errorf("key %d (%s) has no key set", i, key.Name)
+		}
+	}
+
+	return keys, nil
+}
+
+// Server serves a REST API mirroring the LIFX HTTP API, authenticating callers with its own Keys
+// and forwarding their requests to LIFX using a single AccessToken
+type Server struct {
+	client lifx.Client
+	keys   []Key
+}
+
+// NewServer returns a Server that forwards requests with client. Every request shares the client's
+// Limiter, which is created with the LIFX defaults if the client doesn't have one.
+func NewServer(client *lifx.Client, keys []Key) *Server {
+	server := &Server{
+		client: *client,
+		keys:   keys,
+	}
+
+	if server.client.Limiter == nil {
+		server.client.Limiter = lifx.NewRateLimiter(lifx.DefaultRateLimit, lifx.DefaultRatePeriod)
+	}
+
+	return server
+}
+
+// ServeHTTP authenticates the caller and routes the request to the matching filament function
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	key, ok := s.authenticate(r)
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "a valid API key is required")
+		return
+	}
+
+	// Every request gets its own copy of the client, since filament sets the Endpoint on it
+	client := s.client
+	client.Context = r.Context()
+
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/v1"), "/")
+	parts := strings.SplitN(path, "/", 3)
+
+	switch {
+	case r.Method == http.MethodGet && path == "scenes":
+		s.getScenes(w, &client, key)
+	case r.Method == http.MethodPut && path == "lights/states":
+		s.setStates(w, r, &client, key)
+	case r.Method == http.MethodGet && len(parts) == 2 && parts[0] == "lights":
+		s.forward(w, key, parts[1], http.StatusOK, func(selector string) (interface{}, error) {
+			return filament.GetLights(&client, selector)
+		})
+	case r.Method == http.MethodPut && len(parts) == 3 && parts[0] == "scenes" && parts[2] == "activate":
+		s.activateScene(w, r, &client, key, parts[1])
+	case len(parts) == 3 && parts[0] == "lights":
+		s.lights(w, r, &client, key, parts[1], parts[2])
+	default:
+		writeError(w, http.StatusNotFound, "no such endpoint "+r.Method+" "+r.URL.Path)
+	}
+}
+
+// lights handles the write endpoints below /v1/lights/:selector
+func (s *Server) lights(w http.ResponseWriter, r *http.Request, client *lifx.Client, key Key, selector, action string) {
+	var call func(*lifx.Client, string, interface{}) (lifx.Response, error)
+
+	switch r.Method + " " + action {
+	case "PUT state":
+		call = filament.SetState
+	case "POST state/delta":
+		call = filament.StateDelta
+	case "POST cycle":
+		call = filament.Cycle
+	case "POST effects/pulse":
+		call = filament.PulseEffect
+	case "POST effects/breathe":
+		call = filament.BreatheEffect
+	case "POST toggle":
+		call = func(client *lifx.Client, selector string, _ interface{}) (lifx.Response, error) {
+			return filament.TogglePower(client, selector)
+		}
+	default:
+		writeError(w, http.StatusNotFound, "no such endpoint "+r.Method+" "+r.URL.Path)
+		return
+	}
+
+	payload, err := readPayload(w, r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	s.forward(w, key, selector, http.StatusMultiStatus, func(selector string) (interface{}, error) {
+		return call(client, selector, payload)
+	})
+}
+
+func (s *Server) setStates(w http.ResponseWriter, r *http.Request, client *lifx.Client, key Key) {
+	var request struct {
+		States []struct {
+			Selector string `json:"selector"`
+		} `json:"states"`
+	}
+
+	payload, err := readPayload(w, r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	err = json.Unmarshal(payload, &request)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	for _, state := range request.States {
+		if !key.Allows(state.Selector) {
+			writeError(w, http.StatusForbidden, fmt.Sprintf("key %s may not control %q", key.Name, state.Selector))
+			return
+		}
+	}
+
+	response, err := filament.SetStates(client, payload)
+	respond(w, http.StatusMultiStatus, response, err)
+}
+
+// getScenes lists the scenes whose lights the key is allowed to control
+func (s *Server) getScenes(w http.ResponseWriter, client *lifx.Client, key Key) {
+	var allowed []device.Scene
+
+	scenes, err := filament.GetScenes(client)
+	if err != nil {
+		respond(w, http.StatusOK, nil, err)
+		return
+	}
+
+	for _, scene := range scenes {
+		if sceneAllowed(key, scene) {
+			allowed = append(allowed, scene)
+		}
+	}
+
+	respond(w, http.StatusOK, allowed, nil)
+}
+
+func (s *Server) activateScene(w http.ResponseWriter, r *http.Request, client *lifx.Client, key Key, sceneSelector string) {
+	uuid := strings.TrimPrefix(sceneSelector, "scene_id:")
+
+	payload, err := readPayload(w, r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Look the scene up with a copy of the client, so its Endpoint doesn't leak into the activation
+	lookup := *client
+	scenes, err := filament.GetScenes(&lookup)
+	if err != nil {
+		respond(w, http.StatusOK, nil, err)
+		return
+	}
+
+	for _, scene := range scenes {
+		if scene.UUID != uuid {
+			continue
+		}
+
+		if !sceneAllowed(key, scene) {
+			writeError(w, http.StatusForbidden, fmt.Sprintf("key %s may not activate scene %q", key.Name, scene.Name))
+			return
+		}
+
+		response, err := filament.ActivateScene(client, uuid, payload)
+		respond(w, http.StatusMultiStatus, response, err)
+		return
+	}
+
+	writeError(w, http.StatusNotFound, "no such scene "+uuid)
+}
+
+// forward checks the selector against the key's allowlist before making the call. The selector is
+// escaped for the upstream URL, so LIFX sees exactly the selector that was checked.
+func (s *Server) forward(w http.ResponseWriter, key Key, selector string, status int, call func(string) (interface{}, error)) {
+	if !key.Allows(selector) {
+		writeError(w, http.StatusForbidden, fmt.Sprintf("key %s may not control %q", key.Name, selector))
+		return
+	}
+
+	result, err := call(url.PathEscape(selector))
+	respond(w, status, result, err)
+}
+
+func (s *Server) authenticate(r *http.Request) (Key, bool) {
+	header := r.Header.Get("Authorization")
+	if !strings.HasPrefix(header, "Bearer ") {
+		return Key{}, false
+	}
+
+	token := strings.TrimPrefix(header, "Bearer ")
+	if token == "" {
+		return Key{}, false
+	}
+
+	for _, key := range s.keys {
+		if subtle.ConstantTimeCompare([]byte(key.Key), []byte(token)) == 1 {
+			return key, true
+		}
+	}
+
+	return Key{}, false
+}
+
+// sceneAllowed returns true if the key may control every light in the scene. A scene without any
+// states can't be checked, so it is never allowed.
+func sceneAllowed(key Key, scene device.Scene) bool {
+	if len(scene.States) == 0 {
+		return false
+	}
+
+	for _, state := range scene.States {
+		if !key.Allows(state.Selector) {
+			return false
+		}
+	}
+	return true
+}
+
+// readPayload returns the request body, or nil if there isn't one. Bodies over MaxPayload are refused.
+func readPayload(w http.ResponseWriter, r *http.Request) (json.RawMessage, error) {
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, MaxPayload))
+	if err != nil {
+		return nil, fmt.Errorf(err.Error())
+	}
+
+	if len(strings.TrimSpace(string(body))) == 0 {
+		return nil, nil
+	}
+
+	var value interface{}
+	err = json.Unmarshal(body, &value)
+	if err != nil {
+		return nil, fmt.Errorf("the request body must be JSON: %s", err)
+	}
+
+	return json.RawMessage(body), nil
+}
+
+// respond writes result as JSON. If LIFX rejected the request its 4xx status is passed on, since the
+// caller sent something wrong; any other failure forwarding the request is a 502. A LIFX 401 means the
+// proxy's own token is bad, which isn't the caller's fault, so that is a 502 too.
+func respond(w http.ResponseWriter, status int, result interface{}, err error) {
+	var statusErr *lifx.StatusError
+	if errors.As(err, &statusErr) && statusErr.StatusCode >= 400 && statusErr.StatusCode < 500 && statusErr.StatusCode != http.StatusUnauthorized {
+		writeError(w, statusErr.StatusCode, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(result)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
@@ -0,0 +1,187 @@
+package proxy_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/panicpanicpanic/filament/lifx"
+	"github.com/panicpanicpanic/filament/proxy"
+)
+
+func TestServer(t *testing.T) {
+	var upstream struct {
+		method, path, rawPath, token, body string
+	}
+	status := http.StatusMultiStatus
+
+	lifxServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		upstream.method, upstream.path, upstream.token, upstream.body = r.Method, r.URL.Path, r.Header.Get("Authorization"), string(body)
+		upstream.rawPath = r.URL.EscapedPath()
+
+		if r.URL.Path == "/scenes" {
+			w.Write([]byte(`[{"uuid": "empty", "name": "Empty", "states": []}]`))
+			return
+		}
+
+		w.WriteHeader(status)
+		w.Write([]byte(`{"results": [{"id": "d1", "label": "Desk", "status": "ok"}]}`))
+	}))
+	defer lifxServer.Close()
+
+	client := lifx.Client{AccessToken: "someRandomToken", BaseURL: lifxServer.URL}
+	server := proxy.NewServer(&client, []proxy.Key{
+		{Name: "office", Key: "office-key", Selectors: []string{"group:Office", "label:Desk"}},
+	})
+
+	t.Run("when the caller has no API key", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/v1/lights/group:Office/toggle", nil))
+
+		if recorder.Code != http.StatusUnauthorized {
+			t.Errorf("it should have returned a 401 HTTP status, got %d", recorder.Code)
+		}
+	})
+
+	t.Run("when the API key is sent without the Bearer scheme", func(t *testing.T) {
+		request := httptest.NewRequest(http.MethodPost, "/v1/lights/group:Office/toggle", nil)
+		request.Header.Set("Authorization", "office-key")
+
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, request)
+
+		if recorder.Code != http.StatusUnauthorized {
+			t.Errorf("it should have returned a 401 HTTP status, got %d", recorder.Code)
+		}
+	})
+
+	t.Run("when the selector is on the key's allowlist", func(t *testing.T) {
+		request := httptest.NewRequest(http.MethodPut, "/v1/lights/label:Desk/state", strings.NewReader(`{"power":"on"}`))
+		request.Header.Set("Authorization", "Bearer office-key")
+
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, request)
+
+		if recorder.Code != http.StatusMultiStatus {
+			t.Fatalf("it should have returned a 207 HTTP status, got %d: %s", recorder.Code, recorder.Body)
+		}
+		if upstream.method != http.MethodPut || upstream.path != "/lights/label:Desk/state" {
+			t.Errorf("it should have forwarded to the light's state, got %s %s", upstream.method, upstream.path)
+		}
+		if upstream.token != "Bearer someRandomToken" {
+			t.Errorf("it should have swapped in the LIFX access token, got %q", upstream.token)
+		}
+		if !strings.Contains(upstream.body, `"power":"on"`) {
+			t.Errorf("it should have forwarded the payload, got %s", upstream.body)
+		}
+	})
+
+	t.Run("when the selector is not on the key's allowlist", func(t *testing.T) {
+		request := httptest.NewRequest(http.MethodPut, "/v1/lights/label:Desk,group:Lab/state", strings.NewReader(`{"power":"on"}`))
+		request.Header.Set("Authorization", "Bearer office-key")
+
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, request)
+
+		if recorder.Code != http.StatusForbidden {
+			t.Errorf("it should have returned a 403 HTTP status, got %d", recorder.Code)
+		}
+	})
+
+	t.Run("when an encoded comma hides another selector in a zone suffix", func(t *testing.T) {
+		upstream.path = ""
+		request := httptest.NewRequest(http.MethodPut, "/v1/lights/label:Desk|%252Call/state", strings.NewReader(`{"power":"on"}`))
+		request.Header.Set("Authorization", "Bearer office-key")
+
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, request)
+
+		if recorder.Code != http.StatusForbidden || upstream.path != "" {
+			t.Errorf("it should have returned a 403 HTTP status without forwarding, got %d and %q", recorder.Code, upstream.path)
+		}
+	})
+
+	t.Run("when the selector has a zone suffix", func(t *testing.T) {
+		request := httptest.NewRequest(http.MethodPut, "/v1/lights/label:Desk|0-5/state", strings.NewReader(`{"power":"on"}`))
+		request.Header.Set("Authorization", "Bearer office-key")
+
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, request)
+
+		if recorder.Code != http.StatusMultiStatus || upstream.rawPath != "/lights/label:Desk%7C0-5/state" {
+			t.Errorf("it should have forwarded the escaped selector, got %d and %q", recorder.Code, upstream.rawPath)
+		}
+	})
+
+	t.Run("when LIFX rejects the request", func(t *testing.T) {
+		status = http.StatusUnprocessableEntity
+		defer func() { status = http.StatusMultiStatus }()
+
+		request := httptest.NewRequest(http.MethodPut, "/v1/lights/label:Desk/state", strings.NewReader(`{"color":"nope"}`))
+		request.Header.Set("Authorization", "Bearer office-key")
+
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, request)
+
+		if recorder.Code != http.StatusUnprocessableEntity {
+			t.Errorf("it should have passed the 422 HTTP status on, got %d", recorder.Code)
+		}
+	})
+
+	t.Run("when the request body is too large", func(t *testing.T) {
+		body := `{"power":"` + strings.Repeat("x", proxy.MaxPayload) + `"}`
+		request := httptest.NewRequest(http.MethodPut, "/v1/lights/label:Desk/state", strings.NewReader(body))
+		request.Header.Set("Authorization", "Bearer office-key")
+
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, request)
+
+		if recorder.Code != http.StatusBadRequest {
+			t.Errorf("it should have returned a 400 HTTP status, got %d", recorder.Code)
+		}
+	})
+
+	t.Run("when a scene has no states", func(t *testing.T) {
+		request := httptest.NewRequest(http.MethodGet, "/v1/scenes", nil)
+		request.Header.Set("Authorization", "Bearer office-key")
+
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, request)
+
+		if recorder.Code != http.StatusOK || strings.Contains(recorder.Body.String(), "Empty") {
+			t.Errorf("it should have hidden the scene, got %d: %s", recorder.Code, recorder.Body)
+		}
+	})
+
+	t.Run("when a SetStates payload targets a forbidden selector", func(t *testing.T) {
+		request := httptest.NewRequest(http.MethodPut, "/v1/lights/states", strings.NewReader(`{"states":[{"selector":"label:Desk"},{"selector":"all"}]}`))
+		request.Header.Set("Authorization", "Bearer office-key")
+
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, request)
+
+		if recorder.Code != http.StatusForbidden {
+			t.Errorf("it should have returned a 403 HTTP status, got %d", recorder.Code)
+		}
+	})
+}
+
+func TestKeyAllows(t *testing.T) {
+	key := proxy.Key{Selectors: []string{"id:d073d5000000"}}
+
+	if !key.Allows("id:d073d5000000|0-5") {
+		t.Errorf("it should have allowed zones of an allowed device")
+	}
+	if key.Allows("all") {
+		t.Errorf("it should not have allowed every light")
+	}
+
+	for _, selector := range []string{"id:d073d5000000|%2Call", "id:d073d5000000|0,all", "id:d073d5000000|x", "id:d073d5000000/../all", "id:d073d5000000?x"} {
+		if key.Allows(selector) {
+			t.Errorf("it should not have allowed %q", selector)
+		}
+	}
+}
@@ -2,59 +2,52 @@ package service
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
+	"net/url"
+	"strconv"
+	"time"
 
 	"github.com/panicpanicpanic/filament/lifx"
 )
 
+// maxBackoff is the longest a retry waits when LIFX doesn't say when to try again
+const maxBackoff = 10 * time.Second
+
 // Get makes a GET request to the LIFX HTTP API and returns []byte or error
 func Get(client *lifx.Client) ([]byte, error) {
-	var body []byte
-	var httpClient http.Client
-	var err error
-	var statusCode int
-
 	if client.AccessToken == "" || client.Endpoint == "" {
-		return body, fmt.Errorf("In order to access the LIFX API, you must supply a valid AccessToken and Endpoint")
+		return nil, fmt.Errorf("In order to access the LIFX API, you must supply a valid AccessToken and Endpoint")
 	}
 
-	request, err := http.NewRequest(http.MethodGet, client.Endpoint, nil)
-	request.Header.Set("Authorization", "Bearer "+client.AccessToken)
-	if err != nil {
-		return body, fmt.Errorf(err.Error())
-	}
+	return do(client, http.MethodGet, nil)
+}
 
-	response, err := httpClient.Do(request)
+// Put makes a PUT request to the LIFX HTTP API and returns []byte or error
+func Put(client *lifx.Client, payload interface{}) ([]byte, error) {
+	data, err := json.Marshal(payload)
 	if err != nil {
-		return body, fmt.Errorf(err.Error())
+		return nil, fmt.Errorf(err.Error())
 	}
-	defer response.Body.Close()
 
-	body, err = ioutil.ReadAll(response.Body)
-	if err != nil {
-		return body, fmt.Errorf(err.Error())
+	if client.AccessToken == "" || client.Endpoint == "" {
+		return nil, fmt.Errorf("In order to access the LIFX API, you must supply a valid AccessToken and Endpoint")
 	}
 
-	statusCode = response.StatusCode
-	responseString := string(body)
-
-	if statusCode > 207 {
-		return body, fmt.Errorf("Uh oh! You've received a %d status code. Error: %s", statusCode, responseString)
+	body, err := do(client, http.MethodPut, data)
+	if err != nil {
+		return nil, err
 	}
 
 	return body, nil
 }
 
-// Put makes a PUT request to the LIFX HTTP API and returns []byte or error
-func Put(client *lifx.Client, payload interface{}) ([]byte, error) {
-	var body []byte
-	var httpClient http.Client
-	var err error
-	var statusCode int
-
+// Post makes a POST request to the LIFX HTTP API and returns []byte or error
+func Post(client *lifx.Client, payload interface{}) ([]byte, error) {
 	data, err := json.Marshal(payload)
 	if err != nil {
 		return nil, fmt.Errorf(err.Error())
@@ -64,72 +57,170 @@ func Put(client *lifx.Client, payload interface{}) ([]byte, error) {
 		return nil, fmt.Errorf("In order to access the LIFX API, you must supply a valid AccessToken and Endpoint")
 	}
 
-	request, err := http.NewRequest(http.MethodPut, client.Endpoint, bytes.NewBuffer(data))
-	request.Header.Set("Authorization", "Bearer "+client.AccessToken)
+	body, err := do(client, http.MethodPost, data)
 	if err != nil {
-		return nil, fmt.Errorf(err.Error())
+		return nil, err
 	}
 
-	response, err := httpClient.Do(request)
-	if err != nil {
-		return nil, fmt.Errorf(err.Error())
+	return body, nil
+}
+
+// do sends a request to client.Endpoint, waiting on the client's rate limiter before every attempt
+// and retrying up to client.Retries times when the response is retryable
+func do(client *lifx.Client, method string, data []byte) ([]byte, error) {
+	ctx := client.Context
+	if ctx == nil {
+		ctx = context.Background()
 	}
-	defer response.Body.Close()
 
-	body, err = ioutil.ReadAll(response.Body)
-	if err != nil {
-		return nil, fmt.Errorf(err.Error())
+	if len(client.Observers) == 0 {
+		return retry(ctx, client, method, data, &lifx.Call{})
 	}
 
-	statusCode = response.StatusCode
-	responseString := string(body)
+	call := &lifx.Call{Method: method, Request: data}
+	if u, err := url.Parse(client.Endpoint); err == nil {
+		call.Endpoint, call.Selector = lifx.EndpointTemplate(u.Path)
+	}
 
-	if statusCode > 207 {
-		return nil, fmt.Errorf("Uh oh! You've received a %d status code. Error: %s", statusCode, responseString)
+	// Each Observer gets back the context it returned from Begin, even when several are chained
+	contexts := make([]context.Context, len(client.Observers))
+	for i, observer := range client.Observers {
+		ctx = observer.Begin(ctx, call)
+		contexts[i] = ctx
 	}
 
-	return body, nil
+	start := time.Now()
+	body, err := retry(ctx, client, method, data, call)
+	call.Duration = time.Since(start)
+	call.Response = body
+	call.Err = err
+
+	for i := len(client.Observers) - 1; i >= 0; i-- {
+		client.Observers[i].End(contexts[i], call)
+	}
+
+	return body, err
 }
 
-// Post makes a POST request to the LIFX HTTP API and returns []byte or error
-func Post(client *lifx.Client, payload interface{}) ([]byte, error) {
+// retry makes the request until it succeeds or runs out of retries, recording each attempt in call
+func retry(ctx context.Context, client *lifx.Client, method string, data []byte, call *lifx.Call) ([]byte, error) {
+	for attempt := 0; ; attempt++ {
+		call.Attempts = attempt + 1
+
+		body, response, err := send(lifx.WithAttempt(ctx, attempt), client, method, data, call)
+		if err != nil {
+			return body, err
+		}
+
+		statusCode := response.StatusCode
+		call.StatusCode = statusCode
+		call.ResponseHeader = response.Header
+		if statusCode <= 207 {
+			return body, nil
+		}
+
+		if attempt < client.Retries && retryable(method, statusCode) {
+			// Context errors are returned as they are, so callers can still match them with errors.Is
+			err = sleep(ctx, backoff(response, attempt))
+			if err != nil {
+				return body, err
+			}
+			continue
+		}
+
+		return body, &lifx.StatusError{StatusCode: statusCode, Body: string(body)}
+	}
+}
+
+// send makes a single attempt at a request and returns its body and response
+func send(ctx context.Context, client *lifx.Client, method string, data []byte, call *lifx.Call) ([]byte, *http.Response, error) {
 	var body []byte
-	var httpClient http.Client
 	var err error
-	var statusCode int
+	var reader io.Reader
 
-	data, err := json.Marshal(payload)
-	if err != nil {
-		return nil, fmt.Errorf(err.Error())
+	httpClient := client.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
 	}
 
-	if client.AccessToken == "" || client.Endpoint == "" {
-		return nil, fmt.Errorf("In order to access the LIFX API, you must supply a valid AccessToken and Endpoint")
+	if client.Limiter != nil {
+		err = client.Limiter.Wait(ctx)
+		if err != nil {
+			return body, nil, err
+		}
 	}
 
-	request, err := http.NewRequest(http.MethodPost, client.Endpoint, bytes.NewBuffer(data))
-	request.Header.Set("Authorization", "Bearer "+client.AccessToken)
+	if data != nil {
+		reader = bytes.NewReader(data)
+	}
+
+	request, err := http.NewRequest(method, client.Endpoint, reader)
 	if err != nil {
-		return nil, fmt.Errorf(err.Error())
+		return body, nil, fmt.Errorf(err.Error())
 	}
+	request = request.WithContext(ctx)
+	request.Header.Set("Authorization", "Bearer "+client.AccessToken)
+	call.Header = redactHeader(request.Header)
 
+	// These wrap the context's error when it is done, so errors.Is(err, context.Canceled) still works
 	response, err := httpClient.Do(request)
 	if err != nil {
-		return nil, fmt.Errorf(err.Error())
+		return body, nil, err
 	}
 	defer response.Body.Close()
 
 	body, err = ioutil.ReadAll(response.Body)
 	if err != nil {
-		return nil, fmt.Errorf(err.Error())
+		return body, nil, err
 	}
 
-	statusCode = response.StatusCode
-	responseString := string(body)
+	return body, response, nil
+}
 
-	if statusCode > 207 {
-		return nil, fmt.Errorf("Uh oh! You've received a %d status code. Error: %s", statusCode, responseString)
+// redactHeader copies header for Observers, which have no need for the AccessToken
+func redactHeader(header http.Header) http.Header {
+	redacted := make(http.Header, len(header))
+	for key, values := range header {
+		redacted[key] = append([]string(nil), values...)
 	}
 
-	return body, nil
+	redacted.Set("Authorization", "Bearer [REDACTED]")
+	return redacted
+}
+
+// retryable returns true when retrying can't apply the request twice. LIFX rejects rate limited
+// requests outright, but after a server error it may already have applied the request, which is
+// only safe to repeat for GET and PUT. A retried POST could apply a delta twice or toggle back.
+func retryable(method string, statusCode int) bool {
+	if statusCode == http.StatusTooManyRequests {
+		return true
+	}
+	return statusCode >= 500 && (method == http.MethodGet || method == http.MethodPut)
+}
+
+// backoff waits until LIFX resets the rate limit if it said when, or doubles from 250ms otherwise
+func backoff(response *http.Response, attempt int) time.Duration {
+	if reset, err := strconv.ParseInt(response.Header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+		if wait := time.Until(time.Unix(reset, 0)); wait > 0 {
+			return wait
+		}
+	}
+
+	wait := 250 * time.Millisecond << uint(attempt)
+	if wait > maxBackoff {
+		wait = maxBackoff
+	}
+	return wait
+}
+
+func sleep(ctx context.Context, wait time.Duration) error {
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
@@ -1,10 +1,13 @@
 package service_test
 
 import (
+	"context"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/panicpanicpanic/filament/lifx"
 	"github.com/panicpanicpanic/filament/service"
@@ -393,3 +396,143 @@ func TestServicePost(t *testing.T) {
 		}
 	})
 }
+
+func TestServiceRetries(t *testing.T) {
+	var client lifx.Client
+	var attempts int
+	status := http.StatusTooManyRequests
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts < 3 {
+			w.WriteHeader(status)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`[]`))
+	}))
+
+	client.AccessToken = "someRandomToken"
+	client.Endpoint = server.URL
+
+	t.Run("when Retries is not set", func(t *testing.T) {
+		attempts = 0
+
+		_, err := service.Get(&client)
+		if err == nil || attempts != 1 {
+			t.Errorf("it should have given up after 1 attempt, got %d", attempts)
+		}
+	})
+
+	t.Run("when the LIFX API rate limits the request", func(t *testing.T) {
+		attempts = 0
+		client.Retries = 2
+
+		_, err := service.Get(&client)
+		if err != nil {
+			t.Errorf("it should have succeeded after retrying, got %s", err)
+		}
+		if attempts != 3 {
+			t.Errorf("it should have made 3 attempts, got %d", attempts)
+		}
+	})
+
+	t.Run("when a POST hits a LIFX server error", func(t *testing.T) {
+		attempts = 0
+		status = http.StatusBadGateway
+
+		_, err := service.Post(&client, map[string]interface{}{"brightness": 0.1})
+		if err == nil || attempts != 1 {
+			t.Errorf("it should not have retried a request LIFX may have applied, got %d attempts", attempts)
+		}
+	})
+
+	t.Run("when a PUT hits a LIFX server error", func(t *testing.T) {
+		attempts = 0
+		status = http.StatusBadGateway
+
+		_, err := service.Put(&client, map[string]interface{}{"power": "on"})
+		if err != nil || attempts != 3 {
+			t.Errorf("it should have retried the request, got %d attempts and %v", attempts, err)
+		}
+	})
+}
+
+func TestServiceContext(t *testing.T) {
+	var client lifx.Client
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	client.AccessToken = "someRandomToken"
+	client.Endpoint = server.URL
+
+	t.Run("when ctx is cancelled before the request", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		limited := client
+		limited.Context = ctx
+		limited.Limiter = lifx.NewRateLimiter(1, time.Hour)
+
+		for _, c := range []lifx.Client{limited, {AccessToken: client.AccessToken, Endpoint: client.Endpoint, Context: ctx}} {
+			_, err := service.Get(&c)
+			if !errors.Is(err, context.Canceled) {
+				t.Errorf("it should have returned an error matching context.Canceled, got %v", err)
+			}
+		}
+	})
+
+	t.Run("when ctx times out while backing off", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		retrying := client
+		retrying.Context = ctx
+		retrying.Retries = 3
+
+		_, err := service.Get(&retrying)
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("it should have returned an error matching context.DeadlineExceeded, got %v", err)
+		}
+	})
+}
+
+type headerObserver struct {
+	header http.Header
+}
+
+func (o *headerObserver) Begin(ctx context.Context, call *lifx.Call) context.Context {
+	return ctx
+}
+
+func (o *headerObserver) End(ctx context.Context, call *lifx.Call) {
+	o.header = call.Header
+}
+
+func TestCallHeader(t *testing.T) {
+	var authorization string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		w.Write([]byte(`[]`))
+	}))
+	defer server.Close()
+
+	observer := &headerObserver{}
+	client := lifx.Client{AccessToken: "secretToken123", Endpoint: server.URL, Observers: []lifx.Observer{observer}}
+
+	_, err := service.Get(&client)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if authorization != "Bearer secretToken123" {
+		t.Errorf("it should have sent the token to LIFX, got %q", authorization)
+	}
+	if observer.header.Get("Authorization") != "Bearer [REDACTED]" {
+		t.Errorf("it should have redacted the token before handing the header to Observers, got %q", observer.header.Get("Authorization"))
+	}
+}
@@ -0,0 +1,266 @@
+package signal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/panicpanicpanic/filament"
+	"github.com/panicpanicpanic/filament/device"
+	"github.com/panicpanicpanic/filament/effect"
+	"github.com/panicpanicpanic/filament/lifx"
+)
+
+// Names of the built in signals
+const (
+	Info    = "info"
+	Success = "success"
+	Warning = "warning"
+	Error   = "error"
+)
+
+// restoreTimeout bounds restoring the lights once Run's context is done
+const restoreTimeout = 10 * time.Second
+
+// ErrSuppressed is returned when a signal repeats one sent within the Signaler's Suppress window
+var ErrSuppressed = errors.New("signal suppressed as a repeat")
+
+// Preset is how a signal looks on the lights
+type Preset struct {
+	effect.Request
+
+	// Priority decides which signal is shown when several are waiting. A signal with a higher
+	// Priority than the one showing interrupts it.
+	Priority int
+}
+
+// DefaultPresets are used for any signal a Signaler's Presets don't define
+var DefaultPresets = map[string]Preset{
+	Info:    {Request: effect.Request{Waveform: effect.Breathe, Color: "blue", Period: 2, Cycles: 2}, Priority: 1},
+	Success: {Request: effect.Request{Waveform: effect.Pulse, Color: "green", Period: 1, Cycles: 3}, Priority: 2},
+	Warning: {Request: effect.Request{Waveform: effect.Breathe, Color: "orange", Period: 1.5, Cycles: 4}, Priority: 3},
+	Error:   {Request: effect.Alarm, Priority: 4},
+}
+
+// Signaler shows status signals on the lights within Selector. Signals are queued by priority and
+// shown one at a time by Run; once the queue is empty the lights go back to their baseline.
+type Signaler struct {
+	Client   *lifx.Client
+	Selector string
+
+	// Presets override or add to DefaultPresets by signal name
+	Presets map[string]Preset
+
+	// Baseline, if set, is the state the lights are set to once signalling is done. Otherwise the
+	// lights' state from before the first signal is restored.
+	Baseline map[string]interface{}
+
+	// Suppress drops a signal that repeats one accepted less than this long ago
+	Suppress time.Duration
+
+	// OnError, if set, is called when a call to LIFX fails while signalling
+	OnError func(error)
+
+	mutex    sync.Mutex
+	queue    []queued
+	sequence int
+	accepted map[string]time.Time
+	wake     chan struct{}
+	once     sync.Once
+}
+
+// queued is a signal waiting to be shown
+type queued struct {
+	name     string
+	preset   Preset
+	sequence int
+}
+
+// Info queues the info signal
+func (s *Signaler) Info() error {
+	return s.Signal(Info)
+}
+
+// Success queues the success signal
+func (s *Signaler) Success() error {
+	return s.Signal(Success)
+}
+
+// Warning queues the warning signal
+func (s *Signaler) Warning() error {
+	return s.Signal(Warning)
+}
+
+// Error queues the error signal
+func (s *Signaler) Error() error {
+	return s.Signal(Error)
+}
+
+// Signal queues the named signal, looked up in Presets and then DefaultPresets
+func (s *Signaler) Signal(name string) error {
+	preset, ok := s.Presets[name]
+	if !ok {
+		preset, ok = DefaultPresets[name]
+	}
+	if !ok {
+		return fmt.Errorf("no preset for signal %q", name)
+	}
+
+	return s.Custom(name, preset)
+}
+
+// Custom queues a signal shown with preset. Signals with the same name count as repeats.
+func (s *Signaler) Custom(name string, preset Preset) error {
+	err := preset.Validate()
+	if err != nil {
+		return fmt.Errorf("signal %q: %s", name, err.Error())
+	}
+
+	s.mutex.Lock()
+	now := time.Now()
+	if last, ok := s.accepted[name]; ok && s.Suppress > 0 && now.Sub(last) < s.Suppress {
+		s.mutex.Unlock()
+		return ErrSuppressed
+	}
+	if s.accepted == nil {
+		s.accepted = map[string]time.Time{}
+	}
+	s.accepted[name] = now
+
+	s.sequence++
+	s.queue = append(s.queue, queued{name: name, preset: preset, sequence: s.sequence})
+	s.mutex.Unlock()
+
+	select {
+	case s.wakeup() <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Run shows queued signals until ctx is done. If it stops mid signal, the lights are restored before
+// it returns.
+func (s *Signaler) Run(ctx context.Context) error {
+	var current *queued
+	var done <-chan time.Time
+	var snapshot []device.Device
+	var signalling bool
+
+	for {
+		if next := s.take(current); next != nil {
+			// Only capture the lights before the first of a run of signals, not mid way through
+			if !signalling && s.Baseline == nil {
+				snapshot = s.capture(ctx)
+			}
+			signalling = true
+
+			s.show(ctx, *next)
+			current = next
+			done = time.After(next.preset.Duration())
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			if signalling {
+				restoring, cancel := context.WithTimeout(context.WithoutCancel(ctx), restoreTimeout)
+				s.restore(restoring, snapshot)
+				cancel()
+			}
+			return ctx.Err()
+		case <-s.wakeup():
+		case <-done:
+			current, done = nil, nil
+			if s.pending() == 0 {
+				s.restore(ctx, snapshot)
+				snapshot, signalling = nil, false
+			}
+		}
+	}
+}
+
+// take removes and returns the signal to show next: the highest priority one, oldest first, as long
+// as nothing is showing or it outranks what is
+func (s *Signaler) take(current *queued) *queued {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	best := -1
+	for i, waiting := range s.queue {
+		if best < 0 || waiting.preset.Priority > s.queue[best].preset.Priority {
+			best = i
+		}
+	}
+	if best < 0 || (current != nil && s.queue[best].preset.Priority <= current.preset.Priority) {
+		return nil
+	}
+
+	next := s.queue[best]
+	s.queue = append(s.queue[:best], s.queue[best+1:]...)
+	return &next
+}
+
+func (s *Signaler) pending() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return len(s.queue)
+}
+
+func (s *Signaler) show(ctx context.Context, signal queued) {
+	_, err := signal.preset.Send(s.client(ctx), s.Selector)
+	s.report(ctx, err)
+}
+
+// capture records the lights' state so it can be restored once signalling is done
+func (s *Signaler) capture(ctx context.Context) []device.Device {
+	devices, err := filament.GetLights(s.client(ctx), s.Selector)
+	s.report(ctx, err)
+	return devices
+}
+
+// restore puts the lights back to the Baseline, or to the state they were captured in
+func (s *Signaler) restore(ctx context.Context, snapshot []device.Device) {
+	client := s.client(ctx)
+
+	if s.Baseline != nil {
+		_, err := filament.SetState(client, s.Selector, s.Baseline)
+		s.report(ctx, err)
+		return
+	}
+	if len(snapshot) == 0 {
+		return
+	}
+
+	batch := filament.NewBatch()
+	for _, d := range snapshot {
+		batch.Set("id:"+d.ID, map[string]interface{}{
+			"power":      d.Power,
+			"color":      d.Color.String(),
+			"brightness": d.Brightness,
+		})
+	}
+	_, err := batch.Send(client)
+	s.report(ctx, err)
+}
+
+func (s *Signaler) client(ctx context.Context) *lifx.Client {
+	client := *s.Client
+	client.Context = ctx
+	return &client
+}
+
+// report passes err to OnError, unless it only happened because Run is stopping
+func (s *Signaler) report(ctx context.Context, err error) {
+	if err != nil && ctx.Err() == nil && s.OnError != nil {
+		s.OnError(err)
+	}
+}
+
+func (s *Signaler) wakeup() chan struct{} {
+	s.once.Do(func() {
+		s.wake = make(chan struct{}, 1)
+	})
+	return s.wake
+}
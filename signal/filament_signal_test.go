@@ -0,0 +1,154 @@
+package signal_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/panicpanicpanic/filament/effect"
+	"github.com/panicpanicpanic/filament/lifx"
+	"github.com/panicpanicpanic/filament/signal"
+)
+
+type recorder struct {
+	mutex    sync.Mutex
+	requests []string
+}
+
+func (r *recorder) calls() []string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return append([]string(nil), r.requests...)
+}
+
+func (r *recorder) wait(t *testing.T, count int) []string {
+	deadline := time.Now().Add(2 * time.Second)
+	for len(r.calls()) < count && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	return r.calls()
+}
+
+// start runs signaler until the returned func is called or the test ends, whichever is first
+func start(t *testing.T, signaler *signal.Signaler) func() {
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		signaler.Run(ctx)
+		close(stopped)
+	}()
+
+	stop := func() {
+		cancel()
+		<-stopped
+	}
+	t.Cleanup(stop)
+	return stop
+}
+
+func newSignaler(t *testing.T) (*signal.Signaler, *recorder) {
+	record := &recorder{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		record.mutex.Lock()
+		record.requests = append(record.requests, r.Method+" "+r.URL.Path+" "+string(body))
+		record.mutex.Unlock()
+
+		if r.Method == "GET" {
+			w.Write([]byte(`[{"id": "d1", "label": "Lamp", "power": "off", "brightness": 0.5, "color": {"hue": 0, "saturation": 0, "kelvin": 3500}}]`))
+			return
+		}
+		w.Write([]byte(`{"results": [{"id": "d1", "status": "ok"}]}`))
+	}))
+	t.Cleanup(server.Close)
+
+	return &signal.Signaler{
+		Client:   &lifx.Client{AccessToken: "someRandomToken", BaseURL: server.URL},
+		Selector: "group:Team",
+		Presets: map[string]signal.Preset{
+			signal.Info:  {Request: effect.Request{Waveform: effect.Breathe, Color: "blue", Period: 30, Cycles: 2}, Priority: 1},
+			signal.Error: {Request: effect.Request{Waveform: effect.Pulse, Color: "red", Period: 0.05, Cycles: 1}, Priority: 4},
+		},
+		Suppress: time.Minute,
+		OnError:  func(err error) { t.Errorf("it should not have failed, got %s", err) },
+	}, record
+}
+
+func TestSignaler(t *testing.T) {
+	t.Run("when a critical signal arrives during a routine one", func(t *testing.T) {
+		signaler, record := newSignaler(t)
+
+		signaler.Info()
+		start(t, signaler)
+		record.wait(t, 2)
+		signaler.Error()
+
+		calls := record.wait(t, 4)
+		if len(calls) != 4 {
+			t.Fatalf("it should have made 4 calls, got %v", calls)
+		}
+		if !strings.HasPrefix(calls[0], "GET /lights/group:Team") {
+			t.Errorf("it should have captured the baseline first, got %s", calls[0])
+		}
+		if !strings.Contains(calls[1], "/effects/breathe") || !strings.Contains(calls[2], "/effects/pulse") || !strings.Contains(calls[2], `"color":"red"`) {
+			t.Errorf("it should have preempted the breathe with a red pulse, got %v", calls[1:3])
+		}
+		if !strings.HasPrefix(calls[3], "PUT /lights/states") || !strings.Contains(calls[3], `"power":"off"`) {
+			t.Errorf("it should have restored the baseline, got %s", calls[3])
+		}
+	})
+
+	t.Run("when a routine signal arrives during a critical one", func(t *testing.T) {
+		signaler, record := newSignaler(t)
+
+		signaler.Error()
+		signaler.Info()
+		start(t, signaler)
+
+		calls := record.wait(t, 3)
+		if len(calls) != 3 || !strings.Contains(calls[1], "/effects/pulse") || !strings.Contains(calls[2], "/effects/breathe") {
+			t.Errorf("it should have shown the error then the info, got %v", calls)
+		}
+	})
+
+	t.Run("when Run stops mid signal", func(t *testing.T) {
+		signaler, record := newSignaler(t)
+
+		signaler.Info()
+		stop := start(t, signaler)
+		record.wait(t, 2)
+		stop()
+
+		calls := record.calls()
+		if len(calls) != 3 || !strings.HasPrefix(calls[2], "PUT /lights/states") || !strings.Contains(calls[2], `"power":"off"`) {
+			t.Errorf("it should have restored the baseline before returning, got %v", calls)
+		}
+	})
+
+	t.Run("when a signal repeats", func(t *testing.T) {
+		signaler, _ := newSignaler(t)
+
+		if err := signaler.Error(); err != nil {
+			t.Fatal(err)
+		}
+		if err := signaler.Error(); err != signal.ErrSuppressed {
+			t.Errorf("it should have suppressed the repeat, got %v", err)
+		}
+	})
+
+	t.Run("when the signal is unknown", func(t *testing.T) {
+		signaler, _ := newSignaler(t)
+
+		if err := signaler.Signal("deploy"); err == nil {
+			t.Errorf("it should have returned an error")
+		}
+		if err := signaler.Custom("deploy", signal.Preset{Request: effect.Request{Waveform: "flash"}}); err == nil {
+			t.Errorf("it should have rejected the effect")
+		}
+	})
+}
@@ -0,0 +1,79 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/panicpanicpanic/filament/lifx"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName identifies filament's spans to OpenTelemetry
+const instrumentationName = "github.com/panicpanicpanic/filament"
+
+// Observer is a lifx.Observer that records every call made with a Client as an OpenTelemetry span.
+// Spans are children of any span in the Client's Context.
+type Observer struct {
+	tracer trace.Tracer
+}
+
+// NewObserver returns an Observer using provider, or the global TracerProvider if provider is nil
+func NewObserver(provider trace.TracerProvider) *Observer {
+	if provider == nil {
+		provider = otel.GetTracerProvider()
+	}
+
+	return &Observer{tracer: provider.Tracer(instrumentationName)}
+}
+
+// Instrument adds an Observer using provider to the client
+func Instrument(client *lifx.Client, provider trace.TracerProvider) {
+	client.Observers = append(client.Observers, NewObserver(provider))
+}
+
+// Begin starts a client span named after the call's method and endpoint
+func (o *Observer) Begin(ctx context.Context, call *lifx.Call) context.Context {
+	ctx, _ = o.tracer.Start(ctx, "LIFX "+call.Method+" "+call.Endpoint,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("http.method", call.Method),
+			attribute.String("lifx.endpoint", call.Endpoint),
+			attribute.String("lifx.selector", call.Selector),
+		),
+	)
+
+	return ctx
+}
+
+// End records the outcome of the call on its span and ends it
+func (o *Observer) End(ctx context.Context, call *lifx.Call) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.Int("http.status_code", call.StatusCode),
+		attribute.Int("lifx.attempts", call.Attempts),
+		attribute.Int("lifx.retries", call.Attempts-1),
+	)
+
+	// Calls that change lights report a result per light, which is worth summarising by status
+	var response lifx.Response
+	if call.Method != http.MethodGet && json.Unmarshal(call.Response, &response) == nil {
+		counts := make(map[string]int)
+		for _, result := range response.Results {
+			counts[result.Status]++
+		}
+		for status, count := range counts {
+			span.SetAttributes(attribute.Int("lifx.results."+status, count))
+		}
+	}
+
+	if call.Err != nil {
+		span.RecordError(call.Err)
+		span.SetStatus(codes.Error, call.Err.Error())
+	}
+}
@@ -0,0 +1,63 @@
+package tracing_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/panicpanicpanic/filament"
+	"github.com/panicpanicpanic/filament/lifx"
+	"github.com/panicpanicpanic/filament/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestObserver(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusMultiStatus)
+		w.Write([]byte(`{"results": [{"id": "1", "status": "ok"}, {"id": "2", "status": "offline"}, {"id": "3", "status": "ok"}]}`))
+	}))
+	defer server.Close()
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	ctx, parent := provider.Tracer("test").Start(context.Background(), "parent")
+	client := lifx.Client{AccessToken: "someRandomToken", BaseURL: server.URL, Context: ctx}
+	tracing.Instrument(&client, provider)
+
+	_, err := filament.SetState(&client, "group:Office", map[string]interface{}{"power": "on"})
+	if err != nil {
+		t.Fatalf("it should have set the state, got %s", err)
+	}
+	parent.End()
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("it should have recorded the call and its parent, got %d spans", len(spans))
+	}
+
+	span := spans[0]
+	if span.Name() != "LIFX PUT /lights/:selector/state" {
+		t.Errorf("it should have named the span after the endpoint template, got %s", span.Name())
+	}
+	if span.Parent().SpanID() != parent.SpanContext().SpanID() {
+		t.Errorf("it should have been a child of the caller's span")
+	}
+
+	attributes := make(map[attribute.Key]attribute.Value)
+	for _, kv := range span.Attributes() {
+		attributes[kv.Key] = kv.Value
+	}
+	if attributes["lifx.selector"].AsString() != "group:Office" {
+		t.Errorf("it should have recorded the selector, got %v", attributes["lifx.selector"].AsString())
+	}
+	if attributes["lifx.results.ok"].AsInt64() != 2 || attributes["lifx.results.offline"].AsInt64() != 1 {
+		t.Errorf("it should have counted results by status, got %v", span.Attributes())
+	}
+	if attributes["http.status_code"].AsInt64() != http.StatusMultiStatus {
+		t.Errorf("it should have recorded the status code, got %v", attributes["http.status_code"].AsInt64())
+	}
+}
@@ -0,0 +1,196 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/panicpanicpanic/filament"
+	"github.com/panicpanicpanic/filament/device"
+	"github.com/panicpanicpanic/filament/lifx"
+	"github.com/panicpanicpanic/filament/watcher"
+	"golang.org/x/term"
+)
+
+// Run takes over the terminal and shows the lights within selector until the user quits. The lights
+// are polled every interval, and straight after every action.
+func Run(ctx context.Context, client *lifx.Client, selector string, interval time.Duration) error {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return fmt.Errorf("filament tui needs to be run in a terminal")
+	}
+
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return fmt.Errorf(err.Error())
+	}
+	defer term.Restore(fd, state)
+
+	// Use the alternate screen so the user's scrollback is left alone, and hide the cursor
+	fmt.Print("\x1b[?1049h\x1b[?25l")
+	defer fmt.Print("\x1b[?25h\x1b[?1049l")
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	model := &Model{}
+	model.SetStatus("Loading lights...")
+
+	keys := make(chan Key)
+	go readKeys(ctx, os.Stdin, keys)
+
+	devices := make(chan []device.Device)
+	failures := make(chan error, 1)
+	watch := &watcher.Watcher{
+		Client:   client,
+		Selector: selector,
+		Interval: interval,
+		OnError: func(err error) {
+			select {
+			case failures <- err:
+			default:
+			}
+		},
+	}
+	go watch.Run(ctx, func(found []device.Device, _ []watcher.Change) {
+		select {
+		case devices <- found:
+		case <-ctx.Done():
+		}
+	})
+
+	scenes := make(chan []device.Scene, 1)
+	go func() {
+		lookup := *client
+		lookup.Context = ctx
+		found, err := filament.GetScenes(&lookup)
+		if err != nil {
+			select {
+			case failures <- fmt.Errorf("unable to load scenes: %w", err):
+			case <-ctx.Done():
+			}
+			return
+		}
+		scenes <- found
+	}()
+
+	results := make(chan string, 1)
+	for {
+		fmt.Print("\x1b[H\x1b[2J" + model.Render())
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case found := <-devices:
+			model.SetDevices(found)
+			model.SetStatus(fmt.Sprintf("Updated %s", time.Now().Format("15:04:05")))
+		case found := <-scenes:
+			model.SetScenes(found)
+		case err := <-failures:
+			model.SetStatus("Error: " + err.Error())
+		case status := <-results:
+			model.SetStatus(status)
+			watch.Refresh()
+		case key, ok := <-keys:
+			if !ok {
+				return nil
+			}
+
+			action, quit := model.HandleKey(key)
+			if quit {
+				return nil
+			}
+			if action == nil {
+				continue
+			}
+
+			model.SetStatus(action.Description + "...")
+			if action.Call == nil {
+				watch.Refresh()
+				continue
+			}
+			go run(ctx, client, *action, results)
+		}
+	}
+}
+
+// run makes an Action's call with its own copy of the client and reports how it went
+func run(ctx context.Context, client *lifx.Client, action Action, results chan<- string) {
+	actionClient := *client
+	actionClient.Context = ctx
+
+	status := action.Description + " done"
+	if err := action.Call(&actionClient); err != nil {
+		status = action.Description + " failed: " + err.Error()
+	}
+
+	select {
+	case results <- status:
+	case <-ctx.Done():
+	}
+}
+
+// readKeys turns raw terminal input into Keys, closing keys when input ends or ctx is done. Input
+// that supports deadlines, such as a terminal, is interrupted straight away; otherwise readKeys
+// returns after the next read.
+func readKeys(ctx context.Context, input io.Reader, keys chan<- Key) {
+	defer close(keys)
+
+	if deadline, ok := input.(interface{ SetReadDeadline(time.Time) error }); ok {
+		stop := context.AfterFunc(ctx, func() {
+			deadline.SetReadDeadline(time.Now())
+		})
+		defer func() {
+			if stop() {
+				return
+			}
+			deadline.SetReadDeadline(time.Time{})
+		}()
+	}
+
+	buffer := make([]byte, 16)
+	for {
+		n, err := input.Read(buffer)
+		if err != nil || ctx.Err() != nil {
+			return
+		}
+
+		for _, key := range ParseKeys(buffer[:n]) {
+			select {
+			case keys <- key:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// ParseKeys splits raw terminal input into Keys, recognising arrow key escape sequences
+func ParseKeys(input []byte) []Key {
+	var keys []Key
+
+	for i := 0; i < len(input); i++ {
+		switch input[i] {
+		case 3:
+			keys = append(keys, KeyQuit)
+		case '\r', '\n':
+			keys = append(keys, KeyEnter)
+		case 27:
+			if i+2 < len(input) && input[i+1] == '[' {
+				arrows := map[byte]Key{'A': KeyUp, 'B': KeyDown, 'C': KeyRight, 'D': KeyLeft}
+				if key, ok := arrows[input[i+2]]; ok {
+					keys = append(keys, key)
+					i += 2
+					continue
+				}
+			}
+			keys = append(keys, KeyEsc)
+		default:
+			keys = append(keys, Key(input[i:i+1]))
+		}
+	}
+
+	return keys
+}
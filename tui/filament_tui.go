@@ -0,0 +1,326 @@
+package tui
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/panicpanicpanic/filament"
+	"github.com/panicpanicpanic/filament/device"
+	"github.com/panicpanicpanic/filament/hierarchy"
+	"github.com/panicpanicpanic/filament/lifx"
+)
+
+// Key is a single key press, named for special keys ("up", "enter") or the character typed
+type Key string
+
+// Keys with no printable character
+const (
+	KeyUp    Key = "up"
+	KeyDown  Key = "down"
+	KeyLeft  Key = "left"
+	KeyRight Key = "right"
+	KeyEnter Key = "enter"
+	KeyEsc   Key = "esc"
+	KeyQuit  Key = "ctrl+c"
+)
+
+// help lists the keys the Model understands, shown at the bottom of the screen
+const help = "↑↓ move · space toggle · +/- brightness · [/] kelvin · ←/→ hue · p pulse · b breathe · s scenes · r refresh · q quit"
+
+// Action is a call the Model wants made against LIFX. It is run away from the UI so slow calls
+// don't freeze the screen.
+type Action struct {
+	Description string
+	Call        func(client *lifx.Client) error
+}
+
+// Model is the state of the terminal UI: the lights grouped by location and group, the scenes,
+// and which of them is selected
+type Model struct {
+	rows        []row
+	scenes      []device.Scene
+	cursor      int
+	sceneCursor int
+	showScenes  bool
+	status      string
+}
+
+// row is a single line in the light list, either a heading or a light
+type row struct {
+	heading string
+	indent  int
+	device  device.Device
+	light   bool
+}
+
+// SetDevices replaces the lights shown, keeping the same light selected if it is still there
+func (m *Model) SetDevices(devices []device.Device) {
+	selected, hadSelection := m.selected()
+
+	m.rows = nil
+	for _, location := range hierarchy.Build(devices) {
+		m.rows = append(m.rows, row{heading: location.Name})
+		for _, group := range location.Groups {
+			m.rows = append(m.rows, row{heading: group.Name, indent: 1})
+			for _, d := range group.Devices {
+				m.rows = append(m.rows, row{device: d, indent: 2, light: true})
+			}
+		}
+	}
+
+	m.cursor = 0
+	for i, line := range m.lights() {
+		if hadSelection && line.device.ID == selected.ID {
+			m.cursor = i
+		}
+	}
+}
+
+// SetScenes replaces the scenes that can be activated
+func (m *Model) SetScenes(scenes []device.Scene) {
+	m.scenes = scenes
+	if m.sceneCursor >= len(scenes) {
+		m.sceneCursor = 0
+	}
+}
+
+// SetStatus shows a message on the status line
+func (m *Model) SetStatus(status string) {
+	m.status = status
+}
+
+// HandleKey updates the Model for a key press, returning an Action to run if the key asks for one,
+// and whether the UI should quit
+func (m *Model) HandleKey(key Key) (*Action, bool) {
+	switch key {
+	case "q", KeyQuit:
+		return nil, true
+	case "s", KeyEsc:
+		m.showScenes = !m.showScenes && key == "s"
+		return nil, false
+	case "r":
+		return &Action{Description: "Refreshing"}, false
+	}
+
+	if m.showScenes {
+		return m.handleSceneKey(key), false
+	}
+
+	switch key {
+	case KeyUp, "k":
+		m.move(-1)
+		return nil, false
+	case KeyDown, "j":
+		m.move(1)
+		return nil, false
+	}
+
+	d, ok := m.selected()
+	if !ok {
+		return nil, false
+	}
+	selector := "id:" + d.ID
+
+	switch key {
+	case " ", "t":
+		return &Action{Description: "Toggling " + d.Label, Call: func(client *lifx.Client) error {
+			_, err := filament.TogglePower(client, selector)
+			return err
+		}}, false
+	case "+", "=":
+		return delta(d, "Brightening", map[string]interface{}{"brightness": 0.1}), false
+	case "-":
+		return delta(d, "Dimming", map[string]interface{}{"brightness": -0.1}), false
+	case "]":
+		return delta(d, "Cooling", map[string]interface{}{"kelvin": 500}), false
+	case "[":
+		return delta(d, "Warming", map[string]interface{}{"kelvin": -500}), false
+	case KeyRight, "l":
+		return delta(d, "Shifting the hue of", map[string]interface{}{"hue": 30}), false
+	case KeyLeft, "h":
+		return delta(d, "Shifting the hue of", map[string]interface{}{"hue": -30}), false
+	case "p":
+		return &Action{Description: "Pulsing " + d.Label, Call: func(client *lifx.Client) error {
+			_, err := filament.PulseEffect(client, selector, map[string]interface{}{"color": "white", "period": 0.5, "cycles": 3})
+			return err
+		}}, false
+	case "b":
+		return &Action{Description: "Breathing " + d.Label, Call: func(client *lifx.Client) error {
+			_, err := filament.BreatheEffect(client, selector, map[string]interface{}{"color": "white", "period": 2, "cycles": 2})
+			return err
+		}}, false
+	}
+
+	return nil, false
+}
+
+func (m *Model) handleSceneKey(key Key) *Action {
+	switch key {
+	case KeyUp, "k":
+		if m.sceneCursor > 0 {
+			m.sceneCursor--
+		}
+	case KeyDown, "j":
+		if m.sceneCursor < len(m.scenes)-1 {
+			m.sceneCursor++
+		}
+	case KeyEnter:
+		if m.sceneCursor >= len(m.scenes) {
+			return nil
+		}
+
+		scene := m.scenes[m.sceneCursor]
+		m.showScenes = false
+		return &Action{Description: "Activating " + scene.Name, Call: func(client *lifx.Client) error {
+			_, err := filament.ActivateScene(client, scene.UUID, nil)
+			return err
+		}}
+	}
+
+	return nil
+}
+
+// Render draws the Model as lines of text, using ANSI colors for the swatches
+func (m *Model) Render() string {
+	var b strings.Builder
+
+	b.WriteString("\x1b[1mfilament\x1b[0m\r\n\r\n")
+
+	if m.showScenes {
+		m.renderScenes(&b)
+	} else {
+		m.renderLights(&b)
+	}
+
+	fmt.Fprintf(&b, "\r\n%s\r\n\x1b[2m%s\x1b[0m\r\n", m.status, help)
+	return b.String()
+}
+
+func (m *Model) renderLights(b *strings.Builder) {
+	if len(m.rows) == 0 {
+		b.WriteString("  No lights found\r\n")
+		return
+	}
+
+	light := 0
+	for _, line := range m.rows {
+		indent := strings.Repeat("  ", line.indent)
+		if !line.light {
+			fmt.Fprintf(b, "%s\x1b[1m%s\x1b[0m\r\n", indent, line.heading)
+			continue
+		}
+
+		cursor := " "
+		if light == m.cursor {
+			cursor = "›"
+		}
+		light++
+
+		d := line.device
+		status := fmt.Sprintf("%-3s %3.0f%% %5.0fK", d.Power, d.Brightness*100, d.Color.Kelvin)
+		if !d.Connected {
+			status = "offline"
+		}
+
+		fmt.Fprintf(b, "%s%s %s %-24s %s\r\n", cursor, indent, Swatch(d.Color, d.Brightness, d.Power == "on"), d.Label, status)
+	}
+}
+
+func (m *Model) renderScenes(b *strings.Builder) {
+	b.WriteString("\x1b[1mScenes\x1b[0m (enter to activate, esc to go back)\r\n")
+
+	if len(m.scenes) == 0 {
+		b.WriteString("  No scenes found\r\n")
+		return
+	}
+
+	for i, scene := range m.scenes {
+		cursor := " "
+		if i == m.sceneCursor {
+			cursor = "›"
+		}
+		fmt.Fprintf(b, "%s %s (%d lights)\r\n", cursor, scene.Name, len(scene.States))
+	}
+}
+
+func (m *Model) lights() []row {
+	var lights []row
+
+	for _, line := range m.rows {
+		if line.light {
+			lights = append(lights, line)
+		}
+	}
+
+	return lights
+}
+
+func (m *Model) selected() (device.Device, bool) {
+	lights := m.lights()
+	if m.cursor >= len(lights) {
+		return device.Device{}, false
+	}
+	return lights[m.cursor].device, true
+}
+
+func (m *Model) move(by int) {
+	cursor := m.cursor + by
+	if cursor >= 0 && cursor < len(m.lights()) {
+		m.cursor = cursor
+	}
+}
+
+func delta(d device.Device, verb string, payload map[string]interface{}) *Action {
+	return &Action{Description: verb + " " + d.Label, Call: func(client *lifx.Client) error {
+		_, err := filament.StateDelta(client, "id:"+d.ID, payload)
+		return err
+	}}
+}
+
+// Swatch returns a two character block of the color a light is showing, using 24-bit ANSI color
+func Swatch(color device.Color, brightness float64, on bool) string {
+	if !on {
+		return "\x1b[2m░░\x1b[0m"
+	}
+
+	r, g, b := RGB(color, brightness)
+	return fmt.Sprintf("\x1b[38;2;%d;%d;%dm██\x1b[0m", r, g, b)
+}
+
+// RGB approximates the color a light is showing, tinting whites by their kelvin
+func RGB(color device.Color, brightness float64) (uint8, uint8, uint8) {
+	// Whites run from candle orange at 1500K to a blue tint at 9000K
+	warmth := math.Max(0, math.Min(1, (color.Kelvin-1500)/7500))
+	if color.Kelvin == 0 {
+		warmth = 0.5
+	}
+	white := [3]float64{255, 147 + 93*warmth, 41 + 214*warmth}
+
+	// Standard HSV to RGB conversion for the saturated part
+	h := math.Mod(color.Hue, 360) / 60
+	x := 1 - math.Abs(math.Mod(h, 2)-1)
+	var hue [3]float64
+	switch int(h) {
+	case 0:
+		hue = [3]float64{1, x, 0}
+	case 1:
+		hue = [3]float64{x, 1, 0}
+	case 2:
+		hue = [3]float64{0, 1, x}
+	case 3:
+		hue = [3]float64{0, x, 1}
+	case 4:
+		hue = [3]float64{x, 0, 1}
+	default:
+		hue = [3]float64{1, 0, x}
+	}
+
+	var rgb [3]uint8
+	for i := range rgb {
+		mixed := white[i]*(1-color.Saturation) + 255*hue[i]*color.Saturation
+		rgb[i] = uint8(math.Max(0, math.Min(255, mixed*(0.2+0.8*brightness))))
+	}
+
+	return rgb[0], rgb[1], rgb[2]
+}
@@ -0,0 +1,83 @@
+package tui_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/panicpanicpanic/filament/device"
+	"github.com/panicpanicpanic/filament/tui"
+)
+
+func TestParseKeys(t *testing.T) {
+	t.Run("when the input has arrow keys and characters", func(t *testing.T) {
+		keys := tui.ParseKeys([]byte("\x1b[Aj \x1b[D\r\x03"))
+		expected := []tui.Key{tui.KeyUp, "j", " ", tui.KeyLeft, tui.KeyEnter, tui.KeyQuit}
+		if !reflect.DeepEqual(keys, expected) {
+			t.Errorf("it should have parsed %v, got %v", expected, keys)
+		}
+	})
+
+	t.Run("when escape is pressed on its own", func(t *testing.T) {
+		keys := tui.ParseKeys([]byte{27})
+		if len(keys) != 1 || keys[0] != tui.KeyEsc {
+			t.Errorf("it should have parsed esc, got %v", keys)
+		}
+	})
+}
+
+func TestModel(t *testing.T) {
+	home := device.Location{ID: "1", Name: "Home"}
+	room := device.Group{ID: "a", Name: "Lounge"}
+
+	var model tui.Model
+	model.SetDevices([]device.Device{
+		{ID: "d1", Label: "Lamp", Power: "on", Connected: true, Brightness: 1, Location: home, Group: room},
+		{ID: "d2", Label: "Strip", Power: "off", Connected: false, Location: home, Group: room},
+	})
+	model.SetScenes([]device.Scene{{UUID: "s1", Name: "Evening"}})
+
+	t.Run("when rendering the lights", func(t *testing.T) {
+		screen := model.Render()
+		for _, expected := range []string{"Home", "Lounge", "Lamp", "Strip", "offline"} {
+			if !strings.Contains(screen, expected) {
+				t.Errorf("it should have rendered %q, got %q", expected, screen)
+			}
+		}
+	})
+
+	t.Run("when moving down and toggling", func(t *testing.T) {
+		model.HandleKey(tui.KeyDown)
+		action, quit := model.HandleKey(" ")
+		if quit || action == nil || action.Description != "Toggling Strip" {
+			t.Errorf("it should have toggled the second light, got %+v", action)
+		}
+	})
+
+	t.Run("when activating a scene", func(t *testing.T) {
+		model.HandleKey("s")
+		if !strings.Contains(model.Render(), "Evening") {
+			t.Errorf("it should have shown the scenes")
+		}
+
+		action, _ := model.HandleKey(tui.KeyEnter)
+		if action == nil || action.Description != "Activating Evening" {
+			t.Errorf("it should have activated the scene, got %+v", action)
+		}
+	})
+
+	t.Run("when quitting", func(t *testing.T) {
+		if _, quit := model.HandleKey("q"); !quit {
+			t.Errorf("it should have quit")
+		}
+	})
+}
+
+func TestRGB(t *testing.T) {
+	t.Run("when the color is fully saturated red", func(t *testing.T) {
+		r, g, b := tui.RGB(device.Color{Hue: 0, Saturation: 1, Kelvin: 3500}, 1)
+		if r != 255 || g != 0 || b != 0 {
+			t.Errorf("it should have returned pure red, got %d,%d,%d", r, g, b)
+		}
+	})
+}
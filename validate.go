@@ -0,0 +1,93 @@
+package filament
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"github.com/panicpanicpanic/filament/device"
+	"github.com/panicpanicpanic/filament/lifx"
+	"github.com/panicpanicpanic/filament/validation"
+)
+
+// ValidateState checks a SetState payload against the capabilities of every light within the given selector
+func ValidateState(client *lifx.Client, selector string, payload interface{}) (validation.Report, error) {
+	devices, err := knownDevices(client)
+	if err != nil {
+		return validation.Report{}, err
+	}
+
+	return validation.State(devices, selector, payload)
+}
+
+// ValidateStates checks a SetStates payload against the capabilities of every light it targets
+func ValidateStates(client *lifx.Client, payload interface{}) (validation.Report, error) {
+	devices, err := knownDevices(client)
+	if err != nil {
+		return validation.Report{}, err
+	}
+
+	return validation.States(devices, payload)
+}
+
+// ValidateEffect checks a PulseEffect or BreatheEffect payload against every light within the given selector
+func ValidateEffect(client *lifx.Client, selector string, payload interface{}) (validation.Report, error) {
+	devices, err := knownDevices(client)
+	if err != nil {
+		return validation.Report{}, err
+	}
+
+	return validation.Effect(devices, selector, payload)
+}
+
+// knownDevices lists every light on the account without touching the caller's Endpoint
+func knownDevices(client *lifx.Client) ([]device.Device, error) {
+	lookup := *client
+	return GetLights(&lookup, "all")
+}
+
+// strictDevicesTTL is how long Strict mode reuses an account's light list before fetching it again
+const strictDevicesTTL = 30 * time.Second
+
+// strictDevices caches light lists for Strict mode by account and base URL, so checking a payload
+// doesn't cost an extra request against the rate limit every time. Accounts are keyed by a hash of
+// their AccessToken, so tokens aren't kept in memory, and expired lists are dropped.
+var strictDevices = struct {
+	sync.Mutex
+	lists map[string]cachedDevices
+}{lists: map[string]cachedDevices{}}
+
+type cachedDevices struct {
+	devices []device.Device
+	fetched time.Time
+}
+
+// strictReport validates a payload for Strict mode against a cached light list
+func strictReport(client *lifx.Client, validate func([]device.Device) (validation.Report, error)) (validation.Report, error) {
+	hash := sha256.Sum256([]byte(client.AccessToken))
+	key := hex.EncodeToString(hash[:]) + " " + client.URL()
+
+	strictDevices.Lock()
+	for other, list := range strictDevices.lists {
+		if time.Since(list.fetched) > strictDevicesTTL {
+			delete(strictDevices.lists, other)
+		}
+	}
+	cached, ok := strictDevices.lists[key]
+	strictDevices.Unlock()
+
+	if !ok {
+		devices, err := knownDevices(client)
+		if err != nil {
+			return validation.Report{}, err
+		}
+
+		cached = cachedDevices{devices: devices, fetched: time.Now()}
+		strictDevices.Lock()
+		strictDevices.lists[key] = cached
+		strictDevices.Unlock()
+	}
+
+	return validate(cached.devices)
+}
@@ -0,0 +1,98 @@
+package filament_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/panicpanicpanic/filament"
+	"github.com/panicpanicpanic/filament/lifx"
+)
+
+func TestStrict(t *testing.T) {
+	var mutex sync.Mutex
+	requests := map[string]int{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		requests[r.Method]++
+		mutex.Unlock()
+
+		if r.Method == "GET" {
+			w.Write([]byte(`[{"id": "d1", "label": "Desk", "product": {"capabilities": {"has_color": false, "has_variable_color_temp": true, "min_kelvin": 2700, "max_kelvin": 6500}}}]`))
+			return
+		}
+		w.WriteHeader(http.StatusMultiStatus)
+		w.Write([]byte(`{"results": [{"id": "d1", "status": "ok"}]}`))
+	}))
+	defer server.Close()
+
+	client := &lifx.Client{AccessToken: "someRandomToken", BaseURL: server.URL, Strict: true}
+
+	t.Run("when the payload asks for more than the light can do", func(t *testing.T) {
+		_, err := filament.SetState(client, "label:Desk", map[string]interface{}{"color": "kelvin:9000"})
+		if err == nil {
+			t.Errorf("it should have rejected the kelvin")
+		}
+		if requests["PUT"] != 0 {
+			t.Errorf("it should not have sent the state, got %d PUTs", requests["PUT"])
+		}
+	})
+
+	t.Run("when valid states follow", func(t *testing.T) {
+		_, err := filament.SetState(client, "label:Desk", map[string]interface{}{"color": "kelvin:4000"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = filament.SetStates(client, map[string]interface{}{
+			"states": []map[string]interface{}{{"selector": "label:Desk", "brightness": 0.5}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if requests["PUT"] != 2 {
+			t.Errorf("it should have sent both states, got %d PUTs", requests["PUT"])
+		}
+		if requests["GET"] != 1 {
+			t.Errorf("it should have reused the light list, got %d GETs", requests["GET"])
+		}
+	})
+
+	t.Run("when SetStates targets a color on a white light", func(t *testing.T) {
+		_, err := filament.SetStates(client, map[string]interface{}{
+			"states": []map[string]interface{}{{"selector": "label:Desk", "color": "red"}},
+		})
+		if err == nil {
+			t.Errorf("it should have rejected the color")
+		}
+	})
+}
+
+func TestValidateEffect(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"id": "d1", "label": "Desk", "product": {"capabilities": {"has_color": true, "min_kelvin": 2500, "max_kelvin": 9000}}}]`))
+	}))
+	defer server.Close()
+
+	client := &lifx.Client{AccessToken: "someRandomToken", BaseURL: server.URL}
+
+	t.Run("when the effect is out of range", func(t *testing.T) {
+		report, err := filament.ValidateEffect(client, "label:Desk", map[string]interface{}{"color": "red", "peak": 2, "cycles": 0})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if report.Valid() || len(report.Errors()) != 2 {
+			t.Errorf("it should have reported the peak and cycles, got %+v", report.Errors())
+		}
+	})
+
+	t.Run("when the effect is valid", func(t *testing.T) {
+		report, err := filament.ValidateEffect(client, "label:Desk", map[string]interface{}{"color": "red", "from_color": "blue", "period": 1, "cycles": 3})
+		if err != nil || !report.Valid() {
+			t.Errorf("it should have accepted the effect, got %+v %v", report, err)
+		}
+	})
+}
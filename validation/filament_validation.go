@@ -0,0 +1,409 @@
+package validation
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/panicpanicpanic/filament/device"
+)
+
+// maxDuration is the longest transition LIFX accepts, in seconds (100 years)
+const maxDuration = 3155760000
+
+// Severity describes how serious an Issue is
+type Severity int
+
+const (
+	// Warning means LIFX will accept the request but the Device will ignore or adjust part of it
+	Warning Severity = iota
+
+	// Error means the request asks a Device for something it can't do
+	Error
+)
+
+// String returns "warning" or "error"
+func (s Severity) String() string {
+	if s == Error {
+		return "error"
+	}
+	return "warning"
+}
+
+// Issue is a single problem found while validating a request
+type Issue struct {
+	Severity Severity
+	DeviceID string
+	Label    string
+	Field    string
+	Message  string
+}
+
+// String returns a human readable description of the Issue
+func (i Issue) String() string {
+	if i.Label == "" {
+		return fmt.Sprintf("%s: %s: %s", i.Severity, i.Field, i.Message)
+	}
+	return fmt.Sprintf("%s: %s (%s): %s", i.Severity, i.Label, i.Field, i.Message)
+}
+
+// Report holds every Issue found while validating a request
+type Report struct {
+	Issues []Issue
+}
+
+// Errors returns the Issues with Error severity
+func (r Report) Errors() []Issue {
+	return r.filter(Error)
+}
+
+// Warnings returns the Issues with Warning severity
+func (r Report) Warnings() []Issue {
+	return r.filter(Warning)
+}
+
+// Valid returns true if the Report has no errors
+func (r Report) Valid() bool {
+	return len(r.Errors()) == 0
+}
+
+// Err returns an error describing every Error in the Report, or nil if it is valid
+func (r Report) Err() error {
+	var messages []string
+
+	for _, issue := range r.Errors() {
+		messages = append(messages, issue.String())
+	}
+
+	if len(messages) == 0 {
+		return nil
+	}
+	return fmt.Errorf("invalid request: %s", strings.Join(messages, "; "))
+}
+
+func (r Report) filter(severity Severity) []Issue {
+	var issues []Issue
+
+	for _, issue := range r.Issues {
+		if issue.Severity == severity {
+			issues = append(issues, issue)
+		}
+	}
+
+	return issues
+}
+
+func (r *Report) add(severity Severity, d *device.Device, field, format string, args ...interface{}) {
+	issue := Issue{
+		Severity: severity,
+		Field:    field,
+		Message:  fmt.Sprintf(format, args...),
+	}
+
+	if d != nil {
+		issue.DeviceID = d.ID
+		issue.Label = d.Label
+	}
+
+	r.Issues = append(r.Issues, issue)
+}
+
+// State checks a SetState payload against every Device targeted by the selector
+func State(devices []device.Device, selector string, payload interface{}) (Report, error) {
+	var report Report
+
+	fields, err := decode(payload)
+	if err != nil {
+		return report, err
+	}
+
+	checkState(&report, devices, selector, fields)
+	return report, nil
+}
+
+// States checks a SetStates payload, applying its defaults to each state before checking it
+func States(devices []device.Device, payload interface{}) (Report, error) {
+	var report Report
+	var request struct {
+		States   []map[string]interface{} `json:"states"`
+		Defaults map[string]interface{}   `json:"defaults"`
+	}
+
+	err := decodeInto(payload, &request)
+	if err != nil {
+		return report, err
+	}
+
+	for _, state := range request.States {
+		fields := make(map[string]interface{})
+		for key, value := range request.Defaults {
+			fields[key] = value
+		}
+		for key, value := range state {
+			fields[key] = value
+		}
+
+		selector, _ := fields["selector"].(string)
+		checkState(&report, devices, selector, fields)
+	}
+
+	return report, nil
+}
+
+// Effect checks a PulseEffect or BreatheEffect payload against every Device targeted by the selector
+func Effect(devices []device.Device, selector string, payload interface{}) (Report, error) {
+	var report Report
+
+	fields, err := decode(payload)
+	if err != nil {
+		return report, err
+	}
+
+	targets := targeted(&report, devices, selector)
+	for i := range targets {
+		d := &targets[i]
+		checkColor(&report, d, "color", fields["color"])
+		checkColor(&report, d, "from_color", fields["from_color"])
+	}
+
+	checkRange(&report, fields, "peak", 0, 1)
+	checkPositive(&report, fields, "period")
+	checkPositive(&report, fields, "cycles")
+
+	return report, nil
+}
+
+func checkState(report *Report, devices []device.Device, selector string, fields map[string]interface{}) {
+	if power, ok := fields["power"]; ok && power != "on" && power != "off" {
+		report.add(Error, nil, "power", "must be \"on\" or \"off\", got %v", power)
+	}
+
+	checkRange(report, fields, "brightness", 0, 1)
+	checkRange(report, fields, "infrared", 0, 1)
+	checkRange(report, fields, "duration", 0, maxDuration)
+
+	targets := targeted(report, devices, selector)
+	for i := range targets {
+		d := &targets[i]
+		checkColor(report, d, "color", fields["color"])
+		checkZones(report, d, selector)
+
+		if _, ok := fields["infrared"]; ok && !d.Product.Capabilities.HasIR {
+			report.add(Error, d, "infrared", "%s does not support infrared", d.Product.Name)
+		}
+	}
+}
+
+// targeted returns the Devices matching the selector, warning when it matches nothing
+func targeted(report *Report, devices []device.Device, selector string) []device.Device {
+	if selector == "" {
+		selector = "all"
+	}
+
+	targets := device.Filter(devices, selector)
+	if len(targets) == 0 {
+		report.add(Warning, nil, "selector", "%q does not match any known device", selector)
+	}
+
+	return targets
+}
+
+// checkColor parses a LIFX color string and checks it against the Device's capabilities
+func checkColor(report *Report, d *device.Device, field string, value interface{}) {
+	if value == nil {
+		return
+	}
+
+	color, ok := value.(string)
+	if !ok {
+		report.add(Error, d, field, "must be a color string, got %v", value)
+		return
+	}
+
+	needsColor, kelvin, err := parseColor(color)
+	if err != nil {
+		report.add(Error, d, field, "%s", err)
+		return
+	}
+
+	capabilities := d.Product.Capabilities
+	if needsColor && !capabilities.HasColor {
+		report.add(Error, d, field, "%s is white only and can't show %q", d.Product.Name, color)
+	}
+
+	if kelvin == 0 {
+		return
+	}
+
+	if !capabilities.HasVariableColorTemp {
+		report.add(Warning, d, field, "%s has a fixed color temperature and will ignore kelvin:%g", d.Product.Name, kelvin)
+		return
+	}
+
+	if capabilities.MaxKelvin > 0 && (kelvin < capabilities.MinKelvin || kelvin > capabilities.MaxKelvin) {
+		report.add(Error, d, field, "kelvin:%g is outside the %g-%g range %s supports", kelvin, capabilities.MinKelvin, capabilities.MaxKelvin, d.Product.Name)
+	}
+}
+
+// checkZones makes sure zone and tile suffixes only target devices that have them
+func checkZones(report *Report, d *device.Device, selector string) {
+	for _, part := range strings.Split(selector, ",") {
+		part = strings.TrimSpace(part)
+		i := strings.Index(part, "|")
+		if i < 0 || !d.Matches(part) {
+			continue
+		}
+
+		capabilities := d.Product.Capabilities
+		if !capabilities.HasMultizone && !capabilities.HasChain {
+			report.add(Error, d, "selector", "%s has no zones to address with %q", d.Product.Name, part[i:])
+			continue
+		}
+
+		end := part[i+1:]
+		if j := strings.Index(end, "-"); j >= 0 {
+			end = end[j+1:]
+		}
+
+		index, err := strconv.Atoi(end)
+		if err != nil {
+			report.add(Error, d, "selector", "invalid zone range %q", part[i:])
+			continue
+		}
+
+		count := d.ZoneCount()
+		if capabilities.HasChain {
+			count = d.TileCount()
+		}
+		if count > 0 && index >= count {
+			report.add(Error, d, "selector", "zone %d is out of range, %s only has %d", index, d.Label, count)
+		}
+	}
+}
+
+func checkRange(report *Report, fields map[string]interface{}, field string, min, max float64) {
+	value, ok := fields[field]
+	if !ok {
+		return
+	}
+
+	number, ok := value.(float64)
+	if !ok {
+		report.add(Error, nil, field, "must be a number, got %v", value)
+		return
+	}
+
+	if number < min || number > max {
+		report.add(Error, nil, field, "must be between %g and %g, got %g", min, max, number)
+	}
+}
+
+func checkPositive(report *Report, fields map[string]interface{}, field string) {
+	value, ok := fields[field]
+	if !ok {
+		return
+	}
+
+	number, ok := value.(float64)
+	if !ok || number <= 0 {
+		report.add(Error, nil, field, "must be greater than 0, got %v", value)
+	}
+}
+
+// namedColors are the color names LIFX understands, mapped to whether they need a color bulb
+var namedColors = map[string]bool{
+	"white":  false,
+	"red":    true,
+	"orange": true,
+	"yellow": true,
+	"cyan":   true,
+	"green":  true,
+	"blue":   true,
+	"purple": true,
+	"pink":   true,
+}
+
+// parseColor reports whether a LIFX color string needs a color bulb, and the kelvin it asks for
+func parseColor(color string) (bool, float64, error) {
+	var needsColor, hasHue, hasSaturation bool
+	var saturation, kelvin float64
+
+	for _, token := range strings.Fields(strings.ToLower(color)) {
+		if strings.HasPrefix(token, "#") || strings.HasPrefix(token, "rgb:") {
+			needsColor = true
+			continue
+		}
+
+		if colorful, ok := namedColors[token]; ok {
+			needsColor = needsColor || colorful
+			continue
+		}
+
+		i := strings.Index(token, ":")
+		if i < 0 {
+			return false, 0, fmt.Errorf("unknown color %q", token)
+		}
+
+		value, err := strconv.ParseFloat(token[i+1:], 64)
+		if err != nil {
+			return false, 0, fmt.Errorf("invalid color component %q", token)
+		}
+
+		switch token[:i] {
+		case "hue":
+			hasHue = true
+		case "saturation":
+			hasSaturation = true
+			saturation = value
+		case "kelvin":
+			kelvin = value
+		case "brightness":
+		default:
+			return false, 0, fmt.Errorf("unknown color component %q", token)
+		}
+	}
+
+	// A hue with no saturation is still white, which is how device.Color formats white light
+	if hasSaturation {
+		needsColor = needsColor || saturation > 0
+	} else {
+		needsColor = needsColor || hasHue
+	}
+
+	return needsColor, kelvin, nil
+}
+
+// decode turns any payload accepted by the filament functions into a generic map
+func decode(payload interface{}) (map[string]interface{}, error) {
+	fields := make(map[string]interface{})
+
+	err := decodeInto(payload, &fields)
+	return fields, err
+}
+
+func decodeInto(payload interface{}, v interface{}) error {
+	var data []byte
+	var err error
+
+	switch raw := payload.(type) {
+	case nil:
+		return nil
+	case []byte:
+		data = raw
+	case json.RawMessage:
+		data = raw
+	default:
+		data, err = json.Marshal(payload)
+		if err != nil {
+			return fmt.Errorf(err.Error())
+		}
+	}
+
+	err = json.Unmarshal(data, v)
+	if err != nil {
+		return fmt.Errorf(err.Error())
+	}
+
+	return nil
+}
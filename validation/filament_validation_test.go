@@ -0,0 +1,58 @@
+package validation_test
+
+import (
+	"testing"
+
+	"github.com/panicpanicpanic/filament/device"
+	"github.com/panicpanicpanic/filament/validation"
+)
+
+func TestState(t *testing.T) {
+	devices := []device.Device{
+		{ID: "1", Label: "Color", Product: device.Product{Name: "LIFX A19", Capabilities: device.Capabilities{
+			HasColor: true, HasVariableColorTemp: true, MinKelvin: 2500, MaxKelvin: 9000}}},
+		{ID: "2", Label: "White", Product: device.Product{Name: "LIFX Mini White", Capabilities: device.Capabilities{
+			HasVariableColorTemp: true, MinKelvin: 2700, MaxKelvin: 6500}}},
+	}
+
+	t.Run("when kelvin is outside a device's range", func(t *testing.T) {
+		report, err := validation.State(devices, "all", map[string]interface{}{"color": "kelvin:9000"})
+		if err != nil {
+			t.Fatalf("it should have decoded the payload, got %v", err)
+		}
+		if len(report.Errors()) != 1 || report.Errors()[0].DeviceID != "2" {
+			t.Errorf("it should have only rejected the white bulb, got %+v", report.Issues)
+		}
+	})
+
+	t.Run("when a color is sent to a white-only bulb", func(t *testing.T) {
+		report, _ := validation.State(devices, "label:White", map[string]interface{}{"color": "red"})
+		if report.Valid() {
+			t.Errorf("it should have rejected a color for a white-only bulb")
+		}
+
+		report, _ = validation.State(devices, "label:White", map[string]interface{}{"color": "hue:0 saturation:0 kelvin:3500"})
+		if !report.Valid() {
+			t.Errorf("it should have accepted white light, got %+v", report.Issues)
+		}
+	})
+
+	t.Run("when infrared or zones target devices without them", func(t *testing.T) {
+		report, _ := validation.State(devices, "id:1|0-5", map[string]interface{}{"infrared": 0.5})
+		if len(report.Errors()) != 2 {
+			t.Errorf("it should have rejected both infrared and zones, got %+v", report.Issues)
+		}
+	})
+
+	t.Run("when SetStates defaults are invalid", func(t *testing.T) {
+		payload := map[string]interface{}{
+			"states":   []map[string]interface{}{{"selector": "id:1", "power": "on"}},
+			"defaults": map[string]interface{}{"brightness": 2},
+		}
+
+		report, _ := validation.States(devices, payload)
+		if report.Valid() {
+			t.Errorf("it should have applied the defaults before validating")
+		}
+	})
+}
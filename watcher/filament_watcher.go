@@ -0,0 +1,147 @@
+package watcher
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/panicpanicpanic/filament"
+	"github.com/panicpanicpanic/filament/device"
+	"github.com/panicpanicpanic/filament/lifx"
+)
+
+// DefaultInterval is how often a Watcher polls when Interval isn't set
+const DefaultInterval = 10 * time.Second
+
+// Change describes how a single light differs between two polls. Before is nil for lights that
+// appeared, and After is nil for lights that disappeared.
+type Change struct {
+	Before *device.Device
+	After  *device.Device
+	Fields []string
+}
+
+// Watcher polls GetLights and reports what changed between polls
+type Watcher struct {
+	Client   *lifx.Client
+	Selector string
+	Interval time.Duration
+
+	// OnError, if set, is called when a poll fails. Polling carries on regardless.
+	OnError func(error)
+
+	once    sync.Once
+	refresh chan struct{}
+}
+
+// Refresh asks a running Watcher to poll straight away instead of waiting for the next interval
+func (w *Watcher) Refresh() {
+	select {
+	case w.refreshes() <- struct{}{}:
+	default:
+	}
+}
+
+// Run polls until ctx is done, calling handle with every light and the changes since the previous
+// poll. The first poll reports every light as new.
+func (w *Watcher) Run(ctx context.Context, handle func(devices []device.Device, changes []Change)) error {
+	var previous []device.Device
+
+	interval := w.Interval
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		client := *w.Client
+		client.Context = ctx
+
+		devices, err := filament.GetLights(&client, w.Selector)
+		if err != nil {
+			if w.OnError != nil && ctx.Err() == nil {
+				w.OnError(err)
+			}
+		} else {
+			handle(devices, Diff(previous, devices))
+			previous = devices
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		case <-w.refreshes():
+		}
+	}
+}
+
+func (w *Watcher) refreshes() chan struct{} {
+	w.once.Do(func() {
+		w.refresh = make(chan struct{}, 1)
+	})
+	return w.refresh
+}
+
+// Diff compares two polls of the same lights, matching them by ID. Fields that change on every
+// poll, like SecondsSinceSeen, are ignored.
+func Diff(before, after []device.Device) []Change {
+	var changes []Change
+
+	previous := make(map[string]*device.Device, len(before))
+	for i := range before {
+		previous[before[i].ID] = &before[i]
+	}
+
+	for i := range after {
+		current := &after[i]
+
+		old, ok := previous[current.ID]
+		delete(previous, current.ID)
+
+		if !ok {
+			changes = append(changes, Change{After: current})
+			continue
+		}
+
+		fields := changedFields(old, current)
+		if len(fields) > 0 {
+			changes = append(changes, Change{Before: old, After: current, Fields: fields})
+		}
+	}
+
+	for i := range before {
+		if old, ok := previous[before[i].ID]; ok {
+			changes = append(changes, Change{Before: old})
+		}
+	}
+
+	return changes
+}
+
+func changedFields(before, after *device.Device) []string {
+	var fields []string
+
+	if before.Label != after.Label {
+		fields = append(fields, "label")
+	}
+	if before.Connected != after.Connected {
+		fields = append(fields, "connected")
+	}
+	if before.Power != after.Power {
+		fields = append(fields, "power")
+	}
+	if before.Brightness != after.Brightness {
+		fields = append(fields, "brightness")
+	}
+	if before.Color.Hue != after.Color.Hue || before.Color.Saturation != after.Color.Saturation || before.Color.Kelvin != after.Color.Kelvin {
+		fields = append(fields, "color")
+	}
+	if before.Infrared != after.Infrared {
+		fields = append(fields, "infrared")
+	}
+
+	return fields
+}
@@ -0,0 +1,36 @@
+package watcher_test
+
+import (
+	"testing"
+
+	"github.com/panicpanicpanic/filament/device"
+	"github.com/panicpanicpanic/filament/watcher"
+)
+
+func TestDiff(t *testing.T) {
+	before := []device.Device{
+		{ID: "1", Power: "on", Connected: true, SecondsSinceSeen: 1},
+		{ID: "2", Power: "off", Connected: true},
+	}
+	after := []device.Device{
+		{ID: "1", Power: "on", Connected: true, SecondsSinceSeen: 5},
+		{ID: "2", Power: "on", Connected: false},
+		{ID: "3", Power: "off"},
+	}
+
+	changes := watcher.Diff(before, after)
+	if len(changes) != 2 {
+		t.Fatalf("it should have ignored SecondsSinceSeen and found 2 changes, got %+v", changes)
+	}
+	if changes[0].After.ID != "2" || len(changes[0].Fields) != 2 {
+		t.Errorf("it should have reported power and connected changing, got %+v", changes[0].Fields)
+	}
+	if changes[1].Before != nil || changes[1].After.ID != "3" {
+		t.Errorf("it should have reported the new light, got %+v", changes[1])
+	}
+
+	changes = watcher.Diff(after, after[:1])
+	if len(changes) != 2 || changes[0].After != nil {
+		t.Errorf("it should have reported the lights that disappeared, got %+v", changes)
+	}
+}
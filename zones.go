@@ -0,0 +1,243 @@
+package filament
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/panicpanicpanic/filament/device"
+	"github.com/panicpanicpanic/filament/lifx"
+	"github.com/panicpanicpanic/filament/service"
+)
+
+// MaxTilePixels is how many pixels a single TileState can set, as in the LAN protocol's SetTileState64
+const MaxTilePixels = 64
+
+// ZoneSelector narrows a selector for a single multizone device down to the zones start through end
+func ZoneSelector(selector string, start, end int) string {
+	if start == end {
+		return selector + "|" + strconv.Itoa(start)
+	}
+	return fmt.Sprintf("%s|%d-%d", selector, start, end)
+}
+
+// TileSelector narrows a selector for a single chained device down to the tile at index
+func TileSelector(selector string, index int) string {
+	return selector + "|" + strconv.Itoa(index)
+}
+
+// SetZones sets the state of the zones start through end on the multizone device within the given selector
+func SetZones(client *lifx.Client, selector string, start, end int, payload interface{}) (lifx.Response, error) {
+	if start < 0 || end < start {
+		return lifx.Response{}, fmt.Errorf("invalid zone range %d-%d", start, end)
+	}
+
+	return SetState(client, ZoneSelector(selector, start, end), payload)
+}
+
+// SetZoneColors paints colors[i] onto zone i of the multizone device within the given selector.
+// Neighbouring zones with the same color are sent as a single range. Defaults such as duration
+// and brightness are applied to every zone. Devices with too many zones for one SetStates call are
+// painted over several, stopping at the first that fails.
+func SetZoneColors(client *lifx.Client, selector string, colors []device.Color, defaults map[string]interface{}) (lifx.Response, error) {
+	var states []map[string]interface{}
+
+	for start := 0; start < len(colors); {
+		end := start
+		for end+1 < len(colors) && colors[end+1] == colors[start] {
+			end++
+		}
+
+		states = append(states, map[string]interface{}{
+			"selector": ZoneSelector(selector, start, end),
+			"color":    colors[start].String(),
+		})
+		start = end + 1
+	}
+
+	return setStatesInBatches(client, states, defaults)
+}
+
+// PaintGradient fades the zones of the multizone device within the given selector from one color to another
+func PaintGradient(client *lifx.Client, selector string, zones int, from, to device.Color, defaults map[string]interface{}) (lifx.Response, error) {
+	if zones <= 0 {
+		return lifx.Response{}, fmt.Errorf("a gradient needs at least one zone, got %d", zones)
+	}
+
+	return SetZoneColors(client, selector, GradientColors(from, to, zones), defaults)
+}
+
+// GradientColors returns count colors evenly spaced from one color to another. Hues go the short way
+// round the color wheel, so a gradient from 350 to 10 passes through red rather than every other hue.
+func GradientColors(from, to device.Color, count int) []device.Color {
+	colors := make([]device.Color, count)
+
+	for i := range colors {
+		progress := 0.0
+		if count > 1 {
+			progress = float64(i) / float64(count-1)
+		}
+
+		colors[i] = BlendColors(from, to, progress)
+	}
+
+	return colors
+}
+
+// BlendColors returns the color progress (0.0 - 1.0) of the way from one color to another, going the
+// short way round the color wheel like GradientColors. A Kelvin left unset on one end takes the
+// other end's, rather than fading from or to 0.
+func BlendColors(from, to device.Color, progress float64) device.Color {
+	if from.Kelvin == 0 {
+		from.Kelvin = to.Kelvin
+	}
+	if to.Kelvin == 0 {
+		to.Kelvin = from.Kelvin
+	}
+
+	hues := math.Mod(to.Hue-from.Hue, 360)
+	if hues > 180 {
+		hues -= 360
+	} else if hues < -180 {
+		hues += 360
+	}
+
+	return device.Color{
+		Hue:        math.Mod(from.Hue+hues*progress+360, 360),
+		Saturation: from.Saturation + (to.Saturation-from.Saturation)*progress,
+		Kelvin:     from.Kelvin + (to.Kelvin-from.Kelvin)*progress,
+	}
+}
+
+// PaintTiles paints each tile of the chained device within the given selector a single color, colors[i]
+// onto tile i. Use SetTilePixels to color individual pixels. Like SetZoneColors, it stops at the first
+// SetStates call that fails.
+func PaintTiles(client *lifx.Client, selector string, colors []device.Color, defaults map[string]interface{}) (lifx.Response, error) {
+	var states []map[string]interface{}
+
+	for i, color := range colors {
+		states = append(states, map[string]interface{}{
+			"selector": TileSelector(selector, i),
+			"color":    color.String(),
+		})
+	}
+
+	return setStatesInBatches(client, states, defaults)
+}
+
+// TileState sets the pixels of one tile in a chain, like the LAN protocol's SetTileState64 message.
+// Colors fill a Width pixel wide rectangle from X, Y, left to right and then top to bottom.
+type TileState struct {
+	TileIndex int
+	X         int
+	Y         int
+	Width     int
+	Colors    []device.Color
+
+	// Duration is how long, in seconds, the tile takes to fade to the new colors
+	Duration float64
+}
+
+// NewTileState returns a TileState coloring every pixel of tile, row by row from the top left
+func NewTileState(tile device.Tile, colors []device.Color) TileState {
+	return TileState{TileIndex: tile.Index, Width: tile.Width, Colors: colors}
+}
+
+// Validate returns an error if the TileState can't be sent in a single SetTileState64 message
+func (t TileState) Validate() error {
+	if t.TileIndex < 0 || t.X < 0 || t.Y < 0 {
+		return fmt.Errorf("tile %d: index and position can't be negative", t.TileIndex)
+	}
+	if t.Width <= 0 {
+		return fmt.Errorf("tile %d: width must be greater than 0, got %d", t.TileIndex, t.Width)
+	}
+	if len(t.Colors) == 0 || len(t.Colors) > MaxTilePixels {
+		return fmt.Errorf("tile %d: must set between 1 and %d pixels, got %d", t.TileIndex, MaxTilePixels, len(t.Colors))
+	}
+	return nil
+}
+
+// Payload returns the TileState in the set_tile_state64 shape, with colors in the HTTP API's format
+func (t TileState) Payload() map[string]interface{} {
+	colors := make([]string, len(t.Colors))
+	for i, color := range t.Colors {
+		colors[i] = color.String()
+	}
+
+	return map[string]interface{}{
+		"tile_index": t.TileIndex,
+		"length":     1,
+		"x":          t.X,
+		"y":          t.Y,
+		"width":      t.Width,
+		"duration":   t.Duration,
+		"colors":     colors,
+	}
+}
+
+// SetTilePixels sets the pixels of the chained device within the given selector, one TileState per
+// tile, with a PUT to /lights/<selector>/tiles. The documented LIFX HTTP API has no pixel endpoint and
+// answers it with a 404 StatusError, so this is for BaseURLs that bridge to the LAN protocol.
+func SetTilePixels(client *lifx.Client, selector string, states []TileState) (lifx.Response, error) {
+	var body []byte
+	var err error
+	var response lifx.Response
+
+	if len(states) == 0 {
+		return response, fmt.Errorf("no tile states to set")
+	}
+
+	payloads := make([]map[string]interface{}, len(states))
+	for i, state := range states {
+		err = state.Validate()
+		if err != nil {
+			return response, err
+		}
+		payloads[i] = state.Payload()
+	}
+
+	// In order to access LIFX HTTP API, you must pass a valid AccessToken and Endpoint
+	client.Endpoint = client.URL() + "/lights/" + selector + "/tiles"
+
+	body, err = service.Put(client, map[string]interface{}{"states": payloads})
+	if err != nil {
+		return response, err
+	}
+
+	err = json.Unmarshal(body, &response)
+	if err != nil {
+		return response, fmt.Errorf(err.Error())
+	}
+
+	return response, nil
+}
+
+// setStatesInBatches sends states through SetStates in as few calls as LIFX allows and merges the
+// results, stopping at the first call that fails rather than painting the rest of the device around a gap
+func setStatesInBatches(client *lifx.Client, states []map[string]interface{}, defaults map[string]interface{}) (lifx.Response, error) {
+	var response lifx.Response
+
+	for start := 0; start < len(states); start += maxStates {
+		end := start + maxStates
+		if end > len(states) {
+			end = len(states)
+		}
+
+		payload := map[string]interface{}{
+			"states": states[start:end],
+		}
+		if defaults != nil {
+			payload["defaults"] = defaults
+		}
+
+		batch, err := SetStates(client, payload)
+		if err != nil {
+			return response, err
+		}
+
+		response.Results = append(response.Results, batch.Results...)
+	}
+
+	return response, nil
+}
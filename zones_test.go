@@ -0,0 +1,187 @@
+package filament_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/panicpanicpanic/filament"
+	"github.com/panicpanicpanic/filament/device"
+	"github.com/panicpanicpanic/filament/lifx"
+)
+
+func TestZoneSelectors(t *testing.T) {
+	if selector := filament.ZoneSelector("id:d073d5", 2, 5); selector != "id:d073d5|2-5" {
+		t.Errorf("it should have built a zone range, got %s", selector)
+	}
+	if selector := filament.ZoneSelector("id:d073d5", 3, 3); selector != "id:d073d5|3" {
+		t.Errorf("it should have built a single zone, got %s", selector)
+	}
+	if selector := filament.TileSelector("id:d073d5", 1); selector != "id:d073d5|1" {
+		t.Errorf("it should have built a tile selector, got %s", selector)
+	}
+}
+
+func TestGradientColors(t *testing.T) {
+	t.Run("when fading between two hues", func(t *testing.T) {
+		colors := filament.GradientColors(device.Color{Hue: 0, Saturation: 0}, device.Color{Hue: 120, Saturation: 1}, 3)
+		if len(colors) != 3 || colors[1].Hue != 60 || colors[1].Saturation != 0.5 || colors[2].Hue != 120 {
+			t.Errorf("it should have spaced the colors evenly, got %+v", colors)
+		}
+	})
+
+	t.Run("when the short way round passes 360", func(t *testing.T) {
+		colors := filament.GradientColors(device.Color{Hue: 350}, device.Color{Hue: 10}, 3)
+		if colors[1].Hue != 0 || colors[2].Hue != 10 {
+			t.Errorf("it should have gone through red, got %+v", colors)
+		}
+
+		colors = filament.GradientColors(device.Color{Hue: 10}, device.Color{Hue: 350}, 3)
+		if colors[1].Hue != 0 || colors[2].Hue != 350 {
+			t.Errorf("it should have gone back through red, got %+v", colors)
+		}
+	})
+}
+
+func TestGradientKelvin(t *testing.T) {
+	colors := filament.GradientColors(device.Color{Hue: 0}, device.Color{Hue: 120, Kelvin: 3500}, 3)
+	for _, color := range colors {
+		if color.Kelvin != 3500 {
+			t.Errorf("it should have used the end's Kelvin for the unset start, got %+v", colors)
+		}
+	}
+}
+
+func TestSetTilePixels(t *testing.T) {
+	var path string
+	var payload map[string]interface{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.Method + " " + r.URL.Path
+		json.NewDecoder(r.Body).Decode(&payload)
+		w.WriteHeader(http.StatusMultiStatus)
+		w.Write([]byte(`{"results": [{"id": "d073d5", "status": "ok"}]}`))
+	}))
+	defer server.Close()
+
+	client := &lifx.Client{AccessToken: "someRandomToken", BaseURL: server.URL}
+	tile := device.Tile{Index: 1, Width: 8, Height: 8}
+
+	t.Run("when setting every pixel of a tile", func(t *testing.T) {
+		pixels := filament.GradientColors(device.Color{Hue: 0, Saturation: 1}, device.Color{Hue: 240, Saturation: 1}, 64)
+
+		_, err := filament.SetTilePixels(client, "id:d073d5", []filament.TileState{filament.NewTileState(tile, pixels)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if path != "PUT /lights/id:d073d5/tiles" {
+			t.Errorf("it should have put the pixels to the tiles endpoint, got %s", path)
+		}
+
+		state := payload["states"].([]interface{})[0].(map[string]interface{})
+		if state["tile_index"] != 1.0 || state["width"] != 8.0 || len(state["colors"].([]interface{})) != 64 {
+			t.Errorf("it should have sent a set_tile_state64 style payload, got %v", state)
+		}
+		if state["colors"].([]interface{})[63] != "hue:240 saturation:1" {
+			t.Errorf("it should have sent each pixel's color, got %v", state["colors"])
+		}
+	})
+
+	t.Run("when a tile state has too many pixels", func(t *testing.T) {
+		path = ""
+		state := filament.NewTileState(tile, make([]device.Color, 65))
+
+		if _, err := filament.SetTilePixels(client, "id:d073d5", []filament.TileState{state}); err == nil || path != "" {
+			t.Errorf("it should have failed without calling LIFX")
+		}
+	})
+}
+
+func TestZonePainting(t *testing.T) {
+	var payloads []map[string]interface{}
+	var status int
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&payload)
+		payloads = append(payloads, payload)
+
+		w.WriteHeader(status)
+		w.Write([]byte(`{"results": [{"id": "d073d5", "status": "ok"}]}`))
+	}))
+	defer server.Close()
+
+	client := &lifx.Client{AccessToken: "someRandomToken", BaseURL: server.URL}
+	red := device.Color{Hue: 0, Saturation: 1}
+	blue := device.Color{Hue: 240, Saturation: 1}
+
+	selectors := func(payload map[string]interface{}) []string {
+		var selectors []string
+		for _, state := range payload["states"].([]interface{}) {
+			selectors = append(selectors, state.(map[string]interface{})["selector"].(string))
+		}
+		return selectors
+	}
+
+	t.Run("when neighbouring zones share a color", func(t *testing.T) {
+		payloads, status = nil, http.StatusMultiStatus
+
+		_, err := filament.SetZoneColors(client, "id:d073d5", []device.Color{red, red, red, blue}, map[string]interface{}{"duration": 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got := selectors(payloads[0])
+		if len(got) != 2 || got[0] != "id:d073d5|0-2" || got[1] != "id:d073d5|3" {
+			t.Errorf("it should have sent the red zones as one range, got %v", got)
+		}
+		if payloads[0]["defaults"].(map[string]interface{})["duration"] != 1.0 {
+			t.Errorf("it should have sent the defaults, got %v", payloads[0]["defaults"])
+		}
+	})
+
+	t.Run("when painting a gradient with no zones", func(t *testing.T) {
+		if _, err := filament.PaintGradient(client, "id:d073d5", 0, red, blue, nil); err == nil {
+			t.Errorf("it should have returned an error")
+		}
+	})
+
+	t.Run("when painting tiles", func(t *testing.T) {
+		payloads = nil
+
+		_, err := filament.PaintTiles(client, "id:d073d5", []device.Color{red, blue}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got := selectors(payloads[0])
+		if len(got) != 2 || got[0] != "id:d073d5|0" || got[1] != "id:d073d5|1" {
+			t.Errorf("it should have addressed each tile, got %v", got)
+		}
+	})
+
+	t.Run("when a device has more zones than fit in one call", func(t *testing.T) {
+		payloads, status = nil, http.StatusUnprocessableEntity
+
+		colors := make([]device.Color, 120)
+		for i := range colors {
+			colors[i] = device.Color{Hue: float64(i)}
+		}
+
+		if _, err := filament.SetZoneColors(client, "id:d073d5", colors, nil); err == nil || len(payloads) != 1 {
+			t.Errorf("it should have stopped after the first failed call, got %d calls and %v", len(payloads), err)
+		}
+	})
+
+	t.Run("when LIFX rejects the zones", func(t *testing.T) {
+		status = http.StatusUnprocessableEntity
+
+		if _, err := filament.SetZones(client, "id:d073d5", 0, 3, map[string]interface{}{"color": "red"}); err == nil {
+			t.Errorf("it should have returned the error")
+		}
+		if _, err := filament.SetZones(client, "id:d073d5", 3, 1, nil); err == nil {
+			t.Errorf("it should have rejected a backwards range")
+		}
+	})
+}